@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/kube"
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// failoverTargets maps the control plane services the failover scenario can
+// kill to the label selector of their pods.
+var failoverTargets = map[string]string{
+	"scheduler": "app=dapr-scheduler-server",
+	"placement": "app=dapr-placement-server",
+}
+
+type PhaseStats struct {
+	Missed     int `json:"missed"`
+	Duplicates int `json:"duplicates"`
+}
+
+type ReminderFailoverStats struct {
+	ActorID    string      `json:"actor_id"`
+	Reminder   string      `json:"reminder"`
+	Firings    int         `json:"firings"`
+	LastFiring *time.Time  `json:"last_firing,omitempty"`
+	Missed     []time.Time `json:"missed,omitempty"`
+	Duplicates []time.Time `json:"duplicates,omitempty"`
+}
+
+type FailoverReport struct {
+	State   string   `json:"state"`
+	Error   string   `json:"error,omitempty"`
+	Targets []string `json:"targets"`
+	// Mode is "outage" if every pod of the targets was deleted, or
+	// "failover" if one was, for the others to take over.
+	Mode string `json:"mode"`
+	// Replicas is how many pods each target ran before the scenario deleted
+	// any. A failover needs at least 2, which the dev cluster runs of
+	// placement but not of the scheduler, see tools/dapr/Tiltfile.
+	Replicas         map[string]int          `json:"replicas,omitempty"`
+	Warmup           string                  `json:"warmup"`
+	Observe          string                  `json:"observe"`
+	RegisterFailures int                     `json:"register_failures"`
+	DeletedPods      []string                `json:"deleted_pods,omitempty"`
+	StartedAt        time.Time               `json:"started_at"`
+	FailoverAt       *time.Time              `json:"failover_at,omitempty"`
+	RecoveredAt      *time.Time              `json:"recovered_at,omitempty"`
+	FinishedAt       *time.Time              `json:"finished_at,omitempty"`
+	Silent           int                     `json:"silent"`
+	Before           PhaseStats              `json:"before"`
+	During           PhaseStats              `json:"during"`
+	After            PhaseStats              `json:"after"`
+	Reminders        []ReminderFailoverStats `json:"reminders,omitempty"`
+}
+
+// The modes of the failover scenario, see FailoverReport.Mode.
+const (
+	modeOutage   = "outage"
+	modeFailover = "failover"
+)
+
+type failoverParams struct {
+	targets    []string
+	mode       string
+	warmup     time.Duration
+	observe    time.Duration
+	unregister bool
+}
+
+// failoverRunner runs one failover scenario at a time and keeps the report of
+// the last one.
+type failoverRunner struct {
+	client dapr.Client
+	counts *Counts
+	mu     sync.Mutex
+	report *FailoverReport
+}
+
+func newFailoverRunner(client dapr.Client, counts *Counts) *failoverRunner {
+	return &failoverRunner{client: client, counts: counts}
+}
+
+func parseFailoverParams(r *http.Request) (failoverParams, error) {
+	params := failoverParams{
+		targets:    []string{"scheduler", "placement"},
+		mode:       modeOutage,
+		warmup:     15 * time.Second,
+		observe:    60 * time.Second,
+		unregister: true,
+	}
+	q := r.URL.Query()
+	if targets := q.Get("targets"); targets != "" {
+		params.targets = strings.Split(targets, ",")
+	}
+	for _, target := range params.targets {
+		if _, ok := failoverTargets[target]; !ok {
+			return params, fmt.Errorf("unknown target %q", target)
+		}
+	}
+	if mode := q.Get("mode"); mode != "" {
+		if mode != modeOutage && mode != modeFailover {
+			return params, fmt.Errorf("unknown mode %q, expected %s or %s", mode, modeOutage, modeFailover)
+		}
+		params.mode = mode
+	}
+	var err error
+	if warmup := q.Get("warmup"); warmup != "" {
+		if params.warmup, err = time.ParseDuration(warmup); err != nil {
+			return params, fmt.Errorf("invalid warmup: %w", err)
+		}
+	}
+	if observe := q.Get("observe"); observe != "" {
+		if params.observe, err = time.ParseDuration(observe); err != nil {
+			return params, fmt.Errorf("invalid observe: %w", err)
+		}
+	}
+	if q.Get("unregister") == "false" {
+		params.unregister = false
+	}
+	return params, nil
+}
+
+// controlPlaneNamespace is the namespace dapr is installed in, which defaults
+// to the namespace of the app itself.
+func controlPlaneNamespace() string {
+	if ns := os.Getenv("DAPR_NAMESPACE"); ns != "" {
+		return ns
+	}
+	return kube.Namespace()
+}
+
+func (f *failoverRunner) startHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := parseFailoverParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	f.mu.Lock()
+	if f.report != nil && f.report.State == "running" {
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("Failover scenario already running"))
+		return
+	}
+	f.report = &FailoverReport{
+		State:     "running",
+		Targets:   params.targets,
+		Mode:      params.mode,
+		Warmup:    params.warmup.String(),
+		Observe:   params.observe.String(),
+		StartedAt: time.Now(),
+	}
+	f.mu.Unlock()
+
+	go f.run(params)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Failover scenario started"))
+}
+
+func (f *failoverRunner) reportHandler(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.report == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No failover scenario has run yet"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.report)
+}
+
+// update applies fn to the current report while holding the lock.
+func (f *failoverRunner) update(fn func(report *FailoverReport)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f.report)
+}
+
+func (f *failoverRunner) fail(err error) {
+	log.Printf("Failover scenario failed: %s", err.Error())
+	f.update(func(report *FailoverReport) {
+		report.State = "failed"
+		report.Error = err.Error()
+	})
+}
+
+func (f *failoverRunner) run(params failoverParams) {
+	ctx := context.Background()
+	cluster, err := kube.NewInClusterClient()
+	if err != nil {
+		f.fail(err)
+		return
+	}
+	namespace := controlPlaneNamespace()
+
+	if err := f.counts.reset(ctx, f.client); err != nil {
+		log.Printf("Error clearing persisted stats: %s", err.Error())
+	}
+	failed := registerReminders(f.client, f.counts)
+	f.update(func(report *FailoverReport) { report.RegisterFailures = failed })
+	if params.unregister {
+		defer unregisterReminders(f.client, f.counts)
+	}
+
+	log.Printf("Failover scenario: reminders registered, warming up for %s", params.warmup)
+	time.Sleep(params.warmup)
+
+	// List every target before deleting anything, so a target that can't
+	// be disrupted as asked fails the scenario without touching the others.
+	pods := map[string][]kube.Pod{}
+	replicas := map[string]int{}
+	for _, target := range params.targets {
+		list, err := cluster.ListPods(ctx, namespace, failoverTargets[target])
+		if err != nil {
+			f.fail(err)
+			return
+		}
+		if len(list) == 0 {
+			f.fail(fmt.Errorf("no %s pods match %s in namespace %s", target, failoverTargets[target], namespace))
+			return
+		}
+		if params.mode == modeFailover && len(list) < 2 {
+			f.fail(fmt.Errorf("%s runs a single replica, a failover needs at least 2", target))
+			return
+		}
+		pods[target] = list
+		replicas[target] = len(list)
+	}
+	f.update(func(report *FailoverReport) { report.Replicas = replicas })
+
+	// Remember the pods being killed, so recovery waits for their replacements
+	// rather than for the old pods reporting ready one last time.
+	deleted := map[string][]string{}
+	failoverAt := time.Now()
+	f.update(func(report *FailoverReport) { report.FailoverAt = &failoverAt })
+	for _, target := range params.targets {
+		victims := pods[target]
+		if params.mode == modeFailover {
+			victims = victims[:1]
+		}
+		for _, p := range victims {
+			log.Printf("Failover scenario: deleting %s pod %s", target, p.Metadata.Name)
+			if err := cluster.DeletePod(ctx, namespace, p.Metadata.Name); err != nil {
+				f.fail(err)
+				return
+			}
+			deleted[target] = append(deleted[target], p.Metadata.UID)
+			f.update(func(report *FailoverReport) {
+				report.DeletedPods = append(report.DeletedPods, p.Metadata.Name)
+			})
+		}
+	}
+
+	if err := waitForReplacements(ctx, cluster, namespace, deleted, replicas, 5*time.Minute); err != nil {
+		f.fail(err)
+		return
+	}
+	recoveredAt := time.Now()
+	f.update(func(report *FailoverReport) { report.RecoveredAt = &recoveredAt })
+
+	log.Printf("Failover scenario: control plane recovered after %s, observing for %s", recoveredAt.Sub(failoverAt), params.observe)
+	time.Sleep(params.observe)
+
+	finishedAt := time.Now()
+	f.update(func(report *FailoverReport) {
+		report.FinishedAt = &finishedAt
+		analyzeFailover(report, f.counts.clone())
+		report.State = "completed"
+	})
+	log.Printf("Failover scenario completed")
+}
+
+// waitForReplacements waits until every target runs as many ready pods as it
+// had before the failover, given by replicas, none of them being one of the
+// deleted ones.
+func waitForReplacements(ctx context.Context, cluster *kube.Client, namespace string, deleted map[string][]string, replicas map[string]int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		recovered := true
+		for target, uids := range deleted {
+			pods, err := cluster.ListPods(ctx, namespace, failoverTargets[target])
+			if err != nil {
+				return err
+			}
+			ready := 0
+			for _, p := range pods {
+				if p.Ready() && !slices.Contains(uids, p.Metadata.UID) {
+					ready++
+				}
+			}
+			if ready < replicas[target] {
+				recovered = false
+			}
+		}
+		if recovered {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("control plane didn't recover within %s", timeout)
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// analyzeFailover fills the report with the missed and duplicate firings of
+// every reminder, split by whether they happened before, during or after the
+// failover. Reminders that stopped firing before the end of the scenario
+// count the remaining slots as missed.
+func analyzeFailover(report *FailoverReport, snapshot *Counts) {
+	report.Before, report.During, report.After = PhaseStats{}, PhaseStats{}, PhaseStats{}
+	report.Reminders = nil
+	report.Silent = 0
+
+	phase := func(at time.Time) *PhaseStats {
+		switch {
+		case at.Before(*report.FailoverAt):
+			return &report.Before
+		case !at.After(*report.RecoveredAt):
+			return &report.During
+		default:
+			return &report.After
+		}
+	}
+
+	for i := range numReminders {
+		id := reminderActorID(i)
+		var missed []time.Time
+		stats := analyzeFirings(id, snapshot.Calls[id], snapshot.Firings[id], reminderPeriod, snapshot.expectedUntil(id, *report.FinishedAt), func(at time.Time) {
+			missed = append(missed, at)
+			phase(at).Missed++
+		})
+		if stats.LastFiring == nil {
+			report.Silent++
+			continue
+		}
+		for _, at := range stats.DuplicateAt {
+			phase(at).Duplicates++
+		}
+		if len(missed) > 0 || len(stats.DuplicateAt) > 0 {
+			report.Reminders = append(report.Reminders, ReminderFailoverStats{
+				ActorID:    id,
+				Reminder:   reminderName(i),
+				Firings:    stats.Calls,
+				LastFiring: stats.LastFiring,
+				Missed:     missed,
+				Duplicates: stats.DuplicateAt,
+			})
+		}
+	}
+}
@@ -7,100 +7,101 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
 	dapr "github.com/dapr/go-sdk/client"
 	"github.com/gorilla/mux"
 )
 
 var actorType = "testActorType"
 var numReminders = 1000
+var reminderPeriod = 1 * time.Second
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// registerReminders registers the reminder of every test actor, recording the
+// ones registered in counts, and returns how many registrations failed.
+func registerReminders(client dapr.Client, counts *Counts) int {
+	// fraction := time.Second / time.Duration(numReminders)
+	var failed atomic.Int64
+	wg := sync.WaitGroup{}
+	wg.Add(numReminders)
+	for i := range numReminders {
+		go func(i int) {
+			defer wg.Done()
+			err := client.RegisterActorReminder(context.Background(), &dapr.RegisterActorReminderRequest{
+				ActorType: actorType,
+				ActorID:   reminderActorID(i),
+				Name:      reminderName(i),
+				DueTime:   reminderPeriod.String(),
+				Period:    reminderPeriod.String(),
+			})
+			if err != nil {
+				failed.Add(1)
+				log.Printf("Error registering reminder: %s", err.Error())
+				return
+			}
+			counts.registered(reminderActorID(i))
+		}(i)
+	}
+	wg.Wait()
+	return int(failed.Load())
+}
+
+// unregisterReminders removes the reminder of every test actor, recording the
+// ones removed in counts.
+func unregisterReminders(client dapr.Client, counts *Counts) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < numReminders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			at := time.Now()
+			err := client.UnregisterActorReminder(context.Background(), &dapr.UnregisterActorReminderRequest{
+				ActorType: actorType,
+				ActorID:   reminderActorID(i),
+				Name:      reminderName(i),
+			})
+			if err != nil {
+				log.Printf("Error unregistering reminder: %s", err.Error())
+				return
+			}
+			counts.unregistered(reminderActorID(i), at)
+		}(i)
+	}
+	wg.Wait()
 }
 
-func registerActorReminders(client dapr.Client) http.HandlerFunc {
+func registerActorReminders(client dapr.Client, counts *Counts) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// fraction := time.Second / time.Duration(numReminders)
-		wg := sync.WaitGroup{}
-		wg.Add(numReminders)
-		for i := range numReminders {
-			go func(i int) {
-				defer wg.Done()
-				err := client.RegisterActorReminder(context.Background(), &dapr.RegisterActorReminderRequest{
-					ActorType: actorType,
-					ActorID:   fmt.Sprintf("my-actor-id-%d", i),
-					Name:      fmt.Sprintf("my-reminder-%d", i),
-					DueTime:   "1s",
-					Period:    "1s",
-				})
-				if err != nil {
-					log.Printf("Error registering reminder: %s", err.Error())
-				}
-			}(i)
-		}
-		wg.Wait()
+		registerReminders(client, counts)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Reminders registered"))
 	}
 }
 
-func unregisterActorReminder(client dapr.Client) http.HandlerFunc {
+func unregisterActorReminder(client dapr.Client, counts *Counts) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		wg := sync.WaitGroup{}
-		for i := 0; i < numReminders; i++ {
-			wg.Add(1)
-			go func(i int) {
-				defer wg.Done()
-				err := client.UnregisterActorReminder(context.Background(), &dapr.UnregisterActorReminderRequest{
-					ActorType: actorType,
-					ActorID:   fmt.Sprintf("my-actor-id-%d", i),
-					Name:      fmt.Sprintf("my-reminder-%d", i),
-				})
-				if err != nil {
-					log.Printf("Error unregistering reminder: %s", err.Error())
-				}
-			}(i)
-		}
-		wg.Wait()
+		unregisterReminders(client, counts)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Reminders unregistered"))
 	}
 }
 
-type Counts struct {
-	Calls map[string]int
-	mu    sync.Mutex
-}
-
-func NewCounts() *Counts {
-	return &Counts{
-		Calls: make(map[string]int),
-		mu:    sync.Mutex{},
-	}
-}
-func (c *Counts) clone() *Counts {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	clone := NewCounts()
-	for k, v := range c.Calls {
-		clone.Calls[k] = v
-	}
-	return clone
-}
-
 func (c *Counts) actorMethodHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	// actorType := vars["actorType"]
 	actorID := vars["id"]
 	// reminderOrTimer := vars["reminderOrTimer"]
 	// method := vars["method"]
-	c.mu.Lock()
-	c.Calls[actorID]++
-	// c.Calls[fmt.Sprintf("%s/%s/%s/%s", actorType, actorID, reminderOrTimer, method)]++
-	c.mu.Unlock()
+	c.record(actorID, time.Now())
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Actor method called"))
 }
@@ -130,49 +131,32 @@ func shutdownSidecarHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Sidecar shutdown"))
 }
 
-func printStats(counts *Counts) {
-	sums := map[int]int{}
-	counts.mu.Lock()
-	for i := range numReminders {
-		key := fmt.Sprintf("my-actor-id-%d", i)
-		count, ok := counts.Calls[key]
-		if !ok {
-			count = 0
-		}
-		sums[count]++
-	}
-	counts.mu.Unlock()
-	log.Printf("Summarized stats: %#v", sums)
-}
-
-func clearStatsHandler(counts *Counts) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		counts.mu.Lock()
-		counts.Calls = make(map[string]int)
-		counts.mu.Unlock()
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Stats cleared"))
-	}
-}
-
 func main() {
+	app := appkit.New("actors-go", "6010")
+
 	// Create Dapr client
-	client, err := dapr.NewClient()
+	client, err := app.DaprClient()
 	if err != nil {
 		panic(err)
 	}
-	defer client.Close()
 
+	// Restore the firing records persisted before the last restart
 	counts := NewCounts()
+	if err := counts.load(context.Background(), client); err != nil {
+		log.Printf("Could not load persisted stats: %s", err.Error())
+	}
 
 	// Setup HTTP routes
 	router := mux.NewRouter().StrictSlash(true)
-	router.HandleFunc("/healthz", healthHandler).Methods(http.MethodGet)
 	router.HandleFunc("/dapr/config", configHandler).Methods(http.MethodGet)
-	router.HandleFunc("/register-reminder", registerActorReminders(client)).Methods(http.MethodPost)
-	router.HandleFunc("/unregister-reminder", unregisterActorReminder(client)).Methods(http.MethodPost)
-	router.HandleFunc("/clear-stats", clearStatsHandler(counts)).Methods(http.MethodPost)
+	router.HandleFunc("/register-reminder", registerActorReminders(client, counts)).Methods(http.MethodPost)
+	router.HandleFunc("/unregister-reminder", unregisterActorReminder(client, counts)).Methods(http.MethodPost)
+	router.HandleFunc("/stats", statsHandler(counts)).Methods(http.MethodGet)
+	router.HandleFunc("/clear-stats", clearStatsHandler(counts, client)).Methods(http.MethodPost)
 	router.HandleFunc("/shutdown", shutdownSidecarHandler).Methods(http.MethodPost)
+	failover := newFailoverRunner(client, counts)
+	router.HandleFunc("/scenarios/failover", failover.startHandler).Methods(http.MethodPost)
+	router.HandleFunc("/scenarios/failover", failover.reportHandler).Methods(http.MethodGet)
 	router.HandleFunc("/actors/{actorType}/{id}/method/{reminderOrTimer}/{method}", counts.actorMethodHandler).Methods(http.MethodPut)
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Not found: %s\n", r.URL.RequestURI())
@@ -181,18 +165,27 @@ func main() {
 	})
 
 	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
 		for {
-			time.Sleep(1 * time.Second)
+			select {
+			case <-app.Context().Done():
+				return
+			case <-ticker.C:
+			}
 			printStats(counts)
+			if err := counts.flush(context.Background(), client); err != nil {
+				log.Printf("Error persisting stats: %s", err.Error())
+			}
 		}
 	}()
 
-	// Get port from environment variable or use default
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		appPort = "6010"
+	// The app serves /healthz and /readyz itself, and everything else
+	// through the router.
+	app.Handle("/", router)
+	app.CheckSidecar(appkit.SidecarRequirements{Actors: true})
+
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
 	}
-	// Start HTTP server
-	log.Printf("Starting HTTP server on port %s\n", appPort)
-	log.Fatal(http.ListenAndServe(":"+appPort, router))
 }
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// maxFiringsPerActor caps how many firing timestamps are kept per actor, so
+// long runs don't grow the persisted records without bound.
+const maxFiringsPerActor = 600
+
+// statsStore is the Dapr state store the firing records are persisted to. It
+// can be overridden with the STATS_STORE environment variable.
+func statsStore() string {
+	return envOrDefault("STATS_STORE", "statestore")
+}
+
+func statsKey(actorID string) string {
+	return "stats||" + actorID
+}
+
+func reminderActorID(i int) string {
+	return fmt.Sprintf("my-actor-id-%d", i)
+}
+
+func reminderName(i int) string {
+	return fmt.Sprintf("my-reminder-%d", i)
+}
+
+// maxMissedAt caps how many missed firing times are reported per actor, the
+// latest ones. The count of missed firings isn't capped.
+const maxMissedAt = 100
+
+// actorRecord is the persisted form of the stats of a single actor.
+type actorRecord struct {
+	Calls   int         `json:"calls"`
+	Firings []time.Time `json:"firings"`
+	// Registered is whether this app registered the actor's reminder, and
+	// UnregisteredAt when it removed it since.
+	Registered     bool       `json:"registered,omitempty"`
+	UnregisteredAt *time.Time `json:"unregistered_at,omitempty"`
+}
+
+type Counts struct {
+	Calls   map[string]int
+	Firings map[string][]time.Time
+	// Registered holds the actors whose reminder this app registered, and
+	// UnregisteredAt when it removed them since. They're kept on reset, as
+	// the reminders are.
+	Registered     map[string]bool
+	UnregisteredAt map[string]time.Time
+	dirty          map[string]bool
+	mu             sync.Mutex
+	// persist serializes flush and reset, so a reset can't land between the
+	// snapshot a flush takes and its save, and have the flush write back, or
+	// mark dirty again, the records it just dropped.
+	persist sync.Mutex
+}
+
+func NewCounts() *Counts {
+	return &Counts{
+		Calls:          make(map[string]int),
+		Firings:        make(map[string][]time.Time),
+		Registered:     make(map[string]bool),
+		UnregisteredAt: make(map[string]time.Time),
+		dirty:          make(map[string]bool),
+		mu:             sync.Mutex{},
+	}
+}
+
+func (c *Counts) clone() *Counts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clone := NewCounts()
+	for k, v := range c.Calls {
+		clone.Calls[k] = v
+	}
+	for k, v := range c.Firings {
+		clone.Firings[k] = slices.Clone(v)
+	}
+	for k, v := range c.Registered {
+		clone.Registered[k] = v
+	}
+	for k, v := range c.UnregisteredAt {
+		clone.UnregisteredAt[k] = v
+	}
+	return clone
+}
+
+// record registers a reminder firing for the given actor.
+func (c *Counts) record(actorID string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Calls[actorID]++
+	firings := append(c.Firings[actorID], at)
+	if len(firings) > maxFiringsPerActor {
+		firings = firings[len(firings)-maxFiringsPerActor:]
+	}
+	c.Firings[actorID] = firings
+	c.dirty[actorID] = true
+}
+
+// registered records that the reminder of the actor is registered.
+func (c *Counts) registered(actorID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Registered[actorID] = true
+	delete(c.UnregisteredAt, actorID)
+	c.dirty[actorID] = true
+}
+
+// unregistered records that the reminder of the actor was removed at the
+// given time.
+func (c *Counts) unregistered(actorID string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.Registered[actorID] {
+		return
+	}
+	delete(c.Registered, actorID)
+	c.UnregisteredAt[actorID] = at
+	c.dirty[actorID] = true
+}
+
+// expectedUntil returns until when the actor was expected to fire: now while
+// its reminder is registered, or when it was removed. It's the zero time if
+// this app never registered it.
+func (c *Counts) expectedUntil(actorID string, now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Registered[actorID] {
+		return now
+	}
+	if at, ok := c.UnregisteredAt[actorID]; ok && at.Before(now) {
+		return at
+	}
+	return time.Time{}
+}
+
+// persisted returns the persisted form of the stats of the actor. Callers
+// hold c.mu.
+func (c *Counts) persisted(actorID string) actorRecord {
+	record := actorRecord{Calls: c.Calls[actorID], Firings: c.Firings[actorID], Registered: c.Registered[actorID]}
+	if at, ok := c.UnregisteredAt[actorID]; ok {
+		record.UnregisteredAt = &at
+	}
+	return record
+}
+
+// load restores the persisted records of all the test actors.
+func (c *Counts) load(ctx context.Context, client dapr.Client) error {
+	keys := make([]string, 0, numReminders)
+	for i := range numReminders {
+		keys = append(keys, statsKey(reminderActorID(i)))
+	}
+	items, err := client.GetBulkState(ctx, statsStore(), keys, nil, 10)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loaded := 0
+	for _, item := range items {
+		if item.Error != "" || len(item.Value) == 0 {
+			continue
+		}
+		var record actorRecord
+		if err := json.Unmarshal(item.Value, &record); err != nil {
+			log.Printf("Error decoding stats for key %s: %s", item.Key, err.Error())
+			continue
+		}
+		id := strings.TrimPrefix(item.Key, statsKey(""))
+		c.Calls[id] = record.Calls
+		c.Firings[id] = record.Firings
+		if record.Registered {
+			c.Registered[id] = true
+		}
+		if record.UnregisteredAt != nil {
+			c.UnregisteredAt[id] = *record.UnregisteredAt
+		}
+		loaded++
+	}
+	log.Printf("Loaded stats for %d actors from %s", loaded, statsStore())
+	return nil
+}
+
+// flush persists the records of the actors that fired since the last flush.
+func (c *Counts) flush(ctx context.Context, client dapr.Client) error {
+	c.persist.Lock()
+	defer c.persist.Unlock()
+
+	c.mu.Lock()
+	items := make([]*dapr.SetStateItem, 0, len(c.dirty))
+	for id := range c.dirty {
+		value, err := json.Marshal(c.persisted(id))
+		if err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		items = append(items, &dapr.SetStateItem{Key: statsKey(id), Value: value})
+	}
+	dirty := c.dirty
+	c.dirty = make(map[string]bool)
+	c.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	if err := client.SaveBulkState(ctx, statsStore(), items...); err != nil {
+		// Keep them dirty so the next flush retries them.
+		c.mu.Lock()
+		for id := range dirty {
+			c.dirty[id] = true
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// reset drops the firing records, both in memory and in the state store. The
+// registrations stay, as the reminders do.
+func (c *Counts) reset(ctx context.Context, client dapr.Client) error {
+	c.persist.Lock()
+	defer c.persist.Unlock()
+
+	c.mu.Lock()
+	c.Calls = make(map[string]int)
+	c.Firings = make(map[string][]time.Time)
+	c.dirty = make(map[string]bool)
+	// The next flush persists the registrations again.
+	for id := range c.Registered {
+		c.dirty[id] = true
+	}
+	for id := range c.UnregisteredAt {
+		c.dirty[id] = true
+	}
+	c.mu.Unlock()
+
+	keys := make([]string, 0, numReminders)
+	for i := range numReminders {
+		keys = append(keys, statsKey(reminderActorID(i)))
+	}
+	return client.DeleteBulkState(ctx, statsStore(), keys, nil)
+}
+
+type ActorStats struct {
+	ActorID     string     `json:"actor_id"`
+	Calls       int        `json:"calls"`
+	FirstFiring *time.Time `json:"first_firing,omitempty"`
+	LastFiring  *time.Time `json:"last_firing,omitempty"`
+	Missed      int        `json:"missed"`
+	Duplicates  int        `json:"duplicates"`
+	MaxDriftMs  int64      `json:"max_drift_ms"`
+	// MissedAt holds the expected time of the last maxMissedAt missed
+	// firings, and DuplicateAt the time of every firing that came in too
+	// early.
+	MissedAt    []time.Time `json:"missed_at,omitempty"`
+	DuplicateAt []time.Time `json:"duplicate_at,omitempty"`
+}
+
+type StatsResponse struct {
+	ActorType  string       `json:"actor_type"`
+	Period     string       `json:"period"`
+	Actors     int          `json:"actors"`
+	Silent     int          `json:"silent"`
+	Missed     int          `json:"missed"`
+	Duplicates int          `json:"duplicates"`
+	MaxDriftMs int64        `json:"max_drift_ms"`
+	PerActor   []ActorStats `json:"per_actor"`
+}
+
+// analyzeFirings compares the gaps between consecutive firings with the
+// reminder period. Gaps shorter than half a period count as duplicates, gaps
+// spanning several periods count the skipped firings as missed, and drift is
+// how far each firing landed from its closest expected slot. The slots
+// between the last firing and until, when the actor was last expected to
+// fire, count as missed too, for the actors that went quiet. onMissed, if set,
+// is called with every missed slot, past the ones MissedAt keeps.
+func analyzeFirings(actorID string, calls int, firings []time.Time, period time.Duration, until time.Time, onMissed func(at time.Time)) ActorStats {
+	stats := ActorStats{ActorID: actorID, Calls: calls}
+	if len(firings) == 0 {
+		return stats
+	}
+	// missed counts the n slots after from as missed. Without onMissed,
+	// only the ones MissedAt keeps are walked through.
+	missed := func(from time.Time, n int) {
+		stats.Missed += n
+		first := 1
+		if onMissed == nil {
+			first = max(1, n-maxMissedAt+1)
+		}
+		for slot := first; slot <= n; slot++ {
+			at := from.Add(time.Duration(slot) * period)
+			if onMissed != nil {
+				onMissed(at)
+			}
+			stats.MissedAt = append(stats.MissedAt, at)
+		}
+		if len(stats.MissedAt) > maxMissedAt {
+			stats.MissedAt = slices.Clone(stats.MissedAt[len(stats.MissedAt)-maxMissedAt:])
+		}
+	}
+
+	firings = slices.Clone(firings)
+	slices.SortFunc(firings, func(a, b time.Time) int { return a.Compare(b) })
+	stats.FirstFiring = &firings[0]
+	stats.LastFiring = &firings[len(firings)-1]
+
+	var maxDrift time.Duration
+	for i := 1; i < len(firings); i++ {
+		gap := firings[i].Sub(firings[i-1])
+		if gap < period/2 {
+			stats.Duplicates++
+			stats.DuplicateAt = append(stats.DuplicateAt, firings[i])
+			continue
+		}
+		slots := (gap + period/2) / period
+		missed(firings[i-1], int(slots)-1)
+		drift := gap - slots*period
+		if drift < 0 {
+			drift = -drift
+		}
+		maxDrift = max(maxDrift, drift)
+	}
+	// The slots before until, by more than half a period.
+	if until.After(*stats.LastFiring) {
+		if trailing := until.Sub(*stats.LastFiring) - period/2; trailing > 0 {
+			missed(*stats.LastFiring, int((trailing-1)/period))
+		}
+	}
+	stats.MaxDriftMs = maxDrift.Milliseconds()
+	return stats
+}
+
+func buildStats(counts *Counts) StatsResponse {
+	snapshot := counts.clone()
+	now := time.Now()
+	resp := StatsResponse{
+		ActorType: actorType,
+		Period:    reminderPeriod.String(),
+		Actors:    numReminders,
+	}
+	for i := range numReminders {
+		id := reminderActorID(i)
+		stats := analyzeFirings(id, snapshot.Calls[id], snapshot.Firings[id], reminderPeriod, snapshot.expectedUntil(id, now), nil)
+		if stats.Calls == 0 {
+			resp.Silent++
+		}
+		resp.Missed += stats.Missed
+		resp.Duplicates += stats.Duplicates
+		resp.MaxDriftMs = max(resp.MaxDriftMs, stats.MaxDriftMs)
+		resp.PerActor = append(resp.PerActor, stats)
+	}
+	return resp
+}
+
+func printStats(counts *Counts) {
+	sums := map[int]int{}
+	counts.mu.Lock()
+	for i := range numReminders {
+		count, ok := counts.Calls[reminderActorID(i)]
+		if !ok {
+			count = 0
+		}
+		sums[count]++
+	}
+	counts.mu.Unlock()
+	log.Printf("Summarized stats: %#v", sums)
+}
+
+func statsHandler(counts *Counts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildStats(counts))
+	}
+}
+
+func clearStatsHandler(counts *Counts, client dapr.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := counts.reset(r.Context(), client); err != nil {
+			log.Printf("Error clearing persisted stats: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Stats cleared in memory only"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Stats cleared"))
+	}
+}
@@ -1,35 +1,48 @@
 package main
 
 import (
-	"context"
-	"fmt"
+	"log"
+	"log/slog"
 	"strconv"
 	"time"
 
-	dapr "github.com/dapr/go-sdk/client"
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
 )
 
 func main() {
+	// pub doesn't serve HTTP, the app only runs until it's told to stop.
+	app := appkit.New("pub", "")
+
 	// Create a new client for Dapr using the SDK
-	client, err := dapr.NewClient()
+	client, err := app.DaprClient()
 	if err != nil {
 		panic(err.Error())
 	}
-	defer client.Close()
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
 
-	i := 0
-	for {
-		select {
-		case <-ticker.C:
+		i := 0
+		for {
+			select {
+			case <-app.Context().Done():
+				return
+			case <-ticker.C:
+			}
 			i++
-			err := client.PublishEvent(context.Background(), "pubsub", "numbers", []byte(`{"number":`+strconv.Itoa(i)+`}`))
+			err := client.PublishEvent(app.Context(), "pubsub", "numbers", []byte(`{"number":`+strconv.Itoa(i)+`}`))
 			if err != nil {
+				if app.Context().Err() != nil {
+					return
+				}
 				panic(err)
 			}
-			fmt.Println("Published event", i)
+			slog.Info("Published event", "number", i)
 		}
+	}()
+
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
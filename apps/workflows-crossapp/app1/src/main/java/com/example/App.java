@@ -5,10 +5,12 @@ import io.dapr.workflows.runtime.WorkflowRuntimeBuilder;
 import org.springframework.boot.SpringApplication;
 import org.springframework.boot.autoconfigure.SpringBootApplication;
 import org.springframework.http.MediaType;
+import org.springframework.http.ResponseEntity;
 import org.springframework.web.bind.annotation.*;
 
 import java.time.Duration;
 import java.time.OffsetDateTime;
+import java.util.List;
 
 @SpringBootApplication
 public class App {
@@ -28,6 +30,7 @@ class ApiController {
   ApiController() {
     var builder = new WorkflowRuntimeBuilder();
     builder.registerWorkflow(TestWorkflow.class);
+    builder.registerWorkflow(MatrixWorkflow.class);
     builder.registerActivity(TestActivity.class);
     var workflowRuntime = builder.build();
     workflowRuntime.start(false);
@@ -78,5 +81,21 @@ class ApiController {
       return resp;
     }
   }
+
+  // Runs MatrixWorkflow over the given targets and answers with its results,
+  // for workflows-crossapp2 to check the calls from Java.
+  @PostMapping(path = "/matrix", consumes = MediaType.APPLICATION_JSON_VALUE, produces = MediaType.APPLICATION_JSON_VALUE)
+  public ResponseEntity<?> matrix(@RequestBody MatrixWorkflow.Target[] targets) {
+    try {
+      String instanceId = this.workflowClient.scheduleNewWorkflow(MatrixWorkflow.class, targets);
+      var state = this.workflowClient.waitForInstanceCompletion(instanceId, Duration.ofSeconds(90), true);
+      if (state == null || !"COMPLETED".equals(state.getRuntimeStatus().name())) {
+        return ResponseEntity.internalServerError().body("Matrix workflow " + instanceId + " didn't complete");
+      }
+      return ResponseEntity.ok(List.of(state.readOutputAs(MatrixWorkflow.Result[].class)));
+    } catch (Exception e) {
+      return ResponseEntity.internalServerError().body("Failed to run the matrix workflow: " + e.getMessage());
+    }
+  }
 }
 
@@ -0,0 +1,52 @@
+package com.example;
+
+import java.util.ArrayList;
+import java.util.List;
+
+import com.fasterxml.jackson.annotation.JsonIgnoreProperties;
+import io.dapr.durabletask.TaskFailedException;
+import io.dapr.workflows.Workflow;
+import io.dapr.workflows.WorkflowStub;
+import io.dapr.workflows.WorkflowTaskOptions;
+
+// MatrixWorkflow calls every target it's given from Java, for the matrix of
+// workflows-crossapp2, and returns how each call went.
+public class MatrixWorkflow implements Workflow {
+
+  @JsonIgnoreProperties(ignoreUnknown = true)
+  public static class Target {
+    public String app_id;
+    public String activity;
+  }
+
+  public static class Result {
+    public String from = "workflows-crossapp1";
+    public String app_id;
+    public String activity;
+    public String status;
+    public Integer number;
+    public String error;
+  }
+
+  @Override
+  public WorkflowStub create() {
+    return ctx -> {
+      Target[] targets = ctx.getInput(Target[].class);
+      List<Result> results = new ArrayList<>();
+      for (Target target : targets) {
+        Result result = new Result();
+        result.app_id = target.app_id;
+        result.activity = target.activity;
+        try {
+          result.number = ctx.callActivity(target.activity, null, new WorkflowTaskOptions(target.app_id), Integer.class).await();
+          result.status = "passed";
+        } catch (TaskFailedException e) {
+          result.status = "failed";
+          result.error = e.getMessage();
+        }
+        results.add(result);
+      }
+      ctx.complete(results);
+    };
+  }
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// RetryConfig is the retry policy of a cross-app call, in a form requests can
+// set.
+type RetryConfig struct {
+	MaxAttempts            int     `json:"max_attempts"`
+	InitialRetryIntervalMs int64   `json:"initial_retry_interval_ms"`
+	BackoffCoefficient     float64 `json:"backoff_coefficient"`
+	MaxRetryIntervalMs     int64   `json:"max_retry_interval_ms"`
+}
+
+// validate rejects the policies durabletask-go would quietly change, which
+// the checks of the retries wouldn't expect.
+func (c *RetryConfig) validate() error {
+	switch {
+	case c.MaxAttempts < 1:
+		return fmt.Errorf("max_attempts must be at least 1, got %d", c.MaxAttempts)
+	case c.InitialRetryIntervalMs <= 0:
+		return fmt.Errorf("initial_retry_interval_ms must be positive, got %d", c.InitialRetryIntervalMs)
+	case c.BackoffCoefficient <= 0:
+		return fmt.Errorf("backoff_coefficient must be positive, got %g", c.BackoffCoefficient)
+	case c.MaxRetryIntervalMs < 0:
+		return fmt.Errorf("max_retry_interval_ms can't be negative, got %d", c.MaxRetryIntervalMs)
+	}
+	return nil
+}
+
+func (c *RetryConfig) policy() *workflow.RetryPolicy {
+	if c == nil {
+		return nil
+	}
+	return &workflow.RetryPolicy{
+		MaxAttempts:          c.MaxAttempts,
+		InitialRetryInterval: time.Duration(c.InitialRetryIntervalMs) * time.Millisecond,
+		BackoffCoefficient:   c.BackoffCoefficient,
+		MaxRetryInterval:     time.Duration(c.MaxRetryIntervalMs) * time.Millisecond,
+	}
+}
+
+// CrossAppTarget is the activity TestWorkflow2 calls, and the app it calls it
+// in.
+type CrossAppTarget struct {
+	AppID    string `json:"app_id"`
+	Activity string `json:"activity"`
+	// Retry is the retry policy of the call, none if nil.
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// NoRetry asks for no retry policy at all, instead of the default one
+	// an empty Retry falls back to.
+	NoRetry bool `json:"no_retry,omitempty"`
+}
+
+// defaultTarget is what TestWorkflow2 calls unless the request says otherwise.
+var defaultTarget = CrossAppTarget{
+	AppID:    "workflows-crossapp3",
+	Activity: "random_number_generator",
+	Retry: &RetryConfig{
+		MaxAttempts:            3,
+		InitialRetryIntervalMs: 100,
+		BackoffCoefficient:     2,
+		MaxRetryIntervalMs:     1000,
+	},
+}
+
+// matrixTargets are the activities of every app in the set, each returning a
+// random number: the Java app1 registers activities by class name, this app
+// by function name and the Python app3 by the name it gives them. Every app
+// calls every target, so the matrix covers each direction between the three
+// languages.
+var matrixTargets = []CrossAppTarget{
+	{AppID: "workflows-crossapp1", Activity: "com.example.TestActivity"},
+	{AppID: appID, Activity: "TestActivity2"},
+	{AppID: "workflows-crossapp3", Activity: "random_number_generator"},
+}
+
+// withDefaults returns the target with the fields the request left empty
+// taken from defaultTarget. NoRetry drops the default retry policy.
+func (t *CrossAppTarget) withDefaults() CrossAppTarget {
+	target := defaultTarget
+	if t == nil {
+		return target
+	}
+	if t.AppID != "" {
+		target.AppID = t.AppID
+	}
+	if t.Activity != "" {
+		target.Activity = t.Activity
+	}
+	if t.Retry != nil {
+		target.Retry = t.Retry
+	}
+	if t.NoRetry {
+		target.Retry = nil
+	}
+	return target
+}
+
+// callTarget calls the activity of the target, returning the number it
+// returned.
+func callTarget(ctx *workflow.WorkflowContext, target CrossAppTarget) (int, error) {
+	opts := []workflow.CallActivityOption{workflow.WithActivityAppID(target.AppID)}
+	if policy := target.Retry.policy(); policy != nil {
+		opts = append(opts, workflow.WithActivityRetryPolicy(policy))
+	}
+	var number int
+	err := ctx.CallActivity(target.Activity, opts...).Await(&number)
+	return number, err
+}
+
+// MatrixResult is the outcome of calling a target of the matrix from an app.
+type MatrixResult struct {
+	From     string `json:"from"`
+	AppID    string `json:"app_id"`
+	Activity string `json:"activity"`
+	Status   string `json:"status"`
+	Number   int    `json:"number,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type MatrixInput struct {
+	Targets []CrossAppTarget `json:"targets"`
+	// Skipped are the targets that weren't reachable when the workflow
+	// started. Their apps neither call nor get called.
+	Skipped []CrossAppTarget `json:"skipped,omitempty"`
+}
+
+// MatrixWorkflow2 has every reachable app call every reachable target: this
+// app from the workflow itself, the others from a workflow of their own,
+// which RemoteMatrixActivity2 starts through their /matrix endpoint. It
+// reports the results so far as its custom status, and fails if any call
+// fails, after trying them all.
+func MatrixWorkflow2(ctx *workflow.WorkflowContext) (any, error) {
+	var input MatrixInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+
+	var results []MatrixResult
+	skip := func(from string, targets []CrossAppTarget, reason string) {
+		for _, target := range targets {
+			results = append(results, MatrixResult{From: from, AppID: target.AppID, Activity: target.Activity, Status: "skipped", Error: reason})
+		}
+	}
+	for _, caller := range input.Skipped {
+		skip(caller.AppID, slices.Concat(input.Targets, input.Skipped), "caller not reachable")
+	}
+	for _, caller := range input.Targets {
+		skip(caller.AppID, input.Skipped, "app not reachable")
+		if caller.AppID == appID {
+			results = append(results, callTargets(ctx, input.Targets)...)
+		} else {
+			var from []MatrixResult
+			req := RemoteMatrixRequest{Caller: caller.AppID, Targets: input.Targets}
+			if err := ctx.CallActivity(RemoteMatrixActivity2, workflow.WithActivityInput(req)).Await(&from); err != nil {
+				from = nil
+				for _, target := range input.Targets {
+					from = append(from, MatrixResult{From: caller.AppID, AppID: target.AppID, Activity: target.Activity, Status: "failed", Error: err.Error()})
+				}
+			}
+			results = append(results, from...)
+		}
+		status, _ := json.Marshal(results)
+		ctx.SetCustomStatus(string(status))
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == "failed" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("%d of %d cross-app calls failed, see the custom status", failed, len(results))
+	}
+	return results, nil
+}
+
+// callTargets calls every target in turn from this app.
+func callTargets(ctx *workflow.WorkflowContext, targets []CrossAppTarget) []MatrixResult {
+	results := make([]MatrixResult, 0, len(targets))
+	for _, target := range targets {
+		result := MatrixResult{From: appID, AppID: target.AppID, Activity: target.Activity, Status: "passed"}
+		number, err := callTarget(ctx, target)
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		}
+		result.Number = number
+		results = append(results, result)
+	}
+	return results
+}
+
+// remoteMatrixTimeout bounds how long another app gets to call every target.
+const remoteMatrixTimeout = 2 * time.Minute
+
+// RemoteMatrixRequest asks Caller to call every target from its own
+// workflow.
+type RemoteMatrixRequest struct {
+	Caller  string           `json:"caller"`
+	Targets []CrossAppTarget `json:"targets"`
+}
+
+// RemoteMatrixActivity2 has another app call the targets, through its
+// /matrix endpoint, and returns the results it reports.
+func RemoteMatrixActivity2(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var req RemoteMatrixRequest
+	if err := ctx.GetInput(&req); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(req.Targets)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx.Context(), remoteMatrixTimeout)
+	defer cancel()
+	out, err := daprClient.InvokeMethodWithContent(callCtx, req.Caller, "matrix", http.MethodPost, &dapr.DataContent{
+		ContentType: "application/json",
+		Data:        body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the matrix from %s: %w", req.Caller, err)
+	}
+	var results []MatrixResult
+	if err := json.Unmarshal(out, &results); err != nil {
+		return nil, fmt.Errorf("invalid matrix results from %s: %w", req.Caller, err)
+	}
+	for i := range results {
+		results[i].From = req.Caller
+	}
+	return results, nil
+}
+
+// reachableTargets splits matrixTargets by whether their app answers its
+// health check through service invocation.
+func reachableTargets(ctx context.Context) (reachable, unreachable []CrossAppTarget) {
+	for _, target := range matrixTargets {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := daprClient.InvokeMethod(ctx, target.AppID, "healthz", "get")
+		cancel()
+		if err != nil {
+			unreachable = append(unreachable, target)
+			continue
+		}
+		reachable = append(reachable, target)
+	}
+	return reachable, unreachable
+}
+
+// TestWorkflow2 calls the activity of the target in its input.
+func TestWorkflow2(ctx *workflow.WorkflowContext) (any, error) {
+	fmt.Println("TestWorkflow2 called")
+	var target CrossAppTarget
+	if err := ctx.GetInput(&target); err != nil {
+		return nil, err
+	}
+	number, err := callTarget(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return "Workflow completed with number: " + strconv.Itoa(number), nil
+}
@@ -5,7 +5,8 @@ go 1.24.6
 toolchain go1.24.7
 
 require (
-	github.com/dapr/durabletask-go v0.10.0
+	github.com/acroca/dapr-example-app/lib/go v0.0.0-00010101000000-000000000000
+	github.com/dapr/durabletask-go v0.10.1
 	github.com/dapr/go-sdk v1.13.0
 )
 
@@ -28,3 +29,5 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/acroca/dapr-example-app/lib/go => ../../../lib/go
@@ -7,49 +7,47 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
 	"github.com/dapr/durabletask-go/workflow"
-	"github.com/dapr/go-sdk/client"
 	dapr "github.com/dapr/go-sdk/client"
 )
 
+// appID is the app ID of this app.
+const appID = "workflows-crossapp2"
+
+// activities counts the running activities, for the shutdown to drain the
+// worker.
+var activities appkit.InFlight
+
+// wclient runs the worker. Instances are managed through wfClient, which goes
+// through the SDK set in WORKFLOW_CLIENT_BACKEND, durabletask by default.
 var wclient *workflow.Client
+var wfClient wfclient.Client
+var daprClient dapr.Client
 
 type WorkflowRequest struct {
 	Input string `json:"input,omitempty"`
+	// Scenario picks the workflow to run, see scenarios.
+	Scenario string `json:"scenario,omitempty"`
+	// Target overrides the fields it sets of defaultTarget, for the
+	// "cross-app" scenario.
+	Target *CrossAppTarget `json:"target,omitempty"`
 }
 
-type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-}
-
-type WorkflowResponse struct {
-	Status     string `json:"status"`
-	InstanceID string `json:"instance_id"`
-	Result     string `json:"result,omitempty"`
-	Message    string `json:"message,omitempty"`
-	Error      string `json:"error,omitempty"`
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	response := HealthResponse{
-		Status:    "healthy",
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// scenarios maps the scenario names /start accepts to the workflow they run.
+var scenarios = map[string]string{
+	"cross-app": "TestWorkflow2",
+	"local":     "LocalWorkflow2",
+	"matrix":    "MatrixWorkflow2",
 }
 
+// startWorkflowHandler starts the workflow of the requested scenario,
+// "cross-app" by default, and answers 202 with its instance ID without waiting
+// for it. GET /status/{id} reports how it went.
 func startWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -64,135 +62,122 @@ func startWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Use current timestamp as default input if none provided
-	workflowInput := req.Input
-	if workflowInput == "" {
-		workflowInput = time.Now().Format(time.RFC3339)
+	if req.Scenario == "" {
+		req.Scenario = "cross-app"
 	}
-
-	log.Printf("Starting workflow with input: %s", workflowInput)
-
-	// Start workflow
-	id, err := wclient.ScheduleWorkflow(context.Background(), "TestWorkflow2", workflow.WithInput(workflowInput))
-	if err != nil {
-		log.Printf("Error starting workflow: %v", err)
-		response := WorkflowResponse{
-			Status: "failed",
-			Error:  fmt.Sprintf("Failed to start workflow: %v", err),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+	name, ok := scenarios[req.Scenario]
+	if !ok {
+		appkit.WriteError(w, http.StatusBadRequest, "", "Unknown scenario %q", req.Scenario)
 		return
 	}
 
-	log.Printf("Workflow started with instance ID: %s", id)
-
-	// Wait for workflow completion with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	respFetch, err := wclient.WaitForWorkflowCompletion(ctx, id)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Workflow timed out!")
-			response := WorkflowResponse{
-				Status:     "timeout",
-				InstanceID: id,
-				Message:    "Workflow execution timed out",
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusRequestTimeout)
-			json.NewEncoder(w).Encode(response)
+	var workflowInput any
+	switch req.Scenario {
+	case "cross-app":
+		if req.Target != nil && req.Target.NoRetry && req.Target.Retry != nil {
+			appkit.WriteError(w, http.StatusBadRequest, "", "A target can't have both a retry policy and no_retry")
 			return
 		}
-		log.Printf("Error waiting for workflow completion: %v", err)
-		response := WorkflowResponse{
-			Status:     "failed",
-			InstanceID: id,
-			Error:      fmt.Sprintf("Failed to wait for workflow completion: %v", err),
+		target := req.Target.withDefaults()
+		if target.Retry != nil {
+			if err := target.Retry.validate(); err != nil {
+				appkit.WriteError(w, http.StatusBadRequest, "", "Invalid retry policy: %v", err)
+				return
+			}
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	if respFetch.RuntimeStatus.String() != "ORCHESTRATION_STATUS_COMPLETED" {
-		log.Printf("Workflow failed! Status: %s", respFetch.RuntimeStatus.String())
-		response := WorkflowResponse{
-			Status:     "failed",
-			InstanceID: id,
-			Error:      fmt.Sprintf("Workflow failed with status: %s", respFetch.RuntimeStatus.String()),
+		workflowInput = target
+	case "matrix":
+		reachable, unreachable := reachableTargets(r.Context())
+		workflowInput = MatrixInput{Targets: reachable, Skipped: unreachable}
+	default:
+		// Use current timestamp as default input if none provided
+		input := req.Input
+		if input == "" {
+			input = time.Now().Format(time.RFC3339)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
+		workflowInput = input
 	}
 
-	log.Printf("Workflow completed! Result: %s", respFetch.Output.GetValue())
-	response := WorkflowResponse{
-		Status:     "completed",
-		InstanceID: id,
-		Result:     respFetch.Output.GetValue(),
+	log.Printf("Starting workflow %s with input: %v", name, workflowInput)
+
+	// Start workflow
+	id, err := wfClient.ScheduleWorkflow(r.Context(), name, wfclient.WithInput(workflowInput))
+	if err != nil {
+		log.Printf("Error starting workflow: %v", err)
+		appkit.WriteError(w, http.StatusInternalServerError, "", "Failed to start workflow: %v", err)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	log.Printf("Workflow started with instance ID: %s", id)
+	appkit.WriteJSON(w, http.StatusAccepted, appkit.WorkflowResponse{Status: "started", InstanceID: id})
 }
 
 func main() {
+	app := appkit.New(appID, "6009")
+
 	r := workflow.NewRegistry()
 	r.AddWorkflow(TestWorkflow2)
+	r.AddWorkflow(LocalWorkflow2)
+	r.AddWorkflow(MatrixWorkflow2)
+	r.AddWorkflow(RetryTimelineWorkflow2)
 	r.AddActivity(TestActivity2)
+	r.AddActivity(RemoteMatrixActivity2)
 
+	// Create Dapr client
 	var err error
-	wclient, err = client.NewWorkflowClient()
+	daprClient, err = app.DaprClient()
 	if err != nil {
-		log.Fatalf("failed to create workflow client: %v", err)
+		log.Fatalf("failed to create dapr client: %v", err)
 	}
-	wclient.StartWorker(context.Background(), r)
 
-	// Create Dapr client
-	client, err := dapr.NewClient()
+	// The worker shares the Dapr client's connection, so closing the client
+	// on shutdown closes both.
+	wclient = workflow.NewClient(daprClient.GrpcClientConn())
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	if err := wclient.StartWorker(workerCtx, r); err != nil {
+		log.Fatalf("failed to start worker: %v", err)
+	}
+	app.DrainWorker(&activities, stopWorker)
+
+	backend, err := wfclient.BackendFromEnv(wfclient.BackendDurableTask)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	defer client.Close()
+	wfClient, err = wfclient.New(backend, daprClient)
+	if err != nil {
+		log.Fatalf("failed to initialise workflow client: %v", err)
+	}
+	log.Printf("Using the %s workflow client", backend)
 
 	// Setup HTTP routes
-	http.HandleFunc("/healthz", healthHandler)
-	http.HandleFunc("/start", startWorkflowHandler)
+	app.HandleFunc("/start", app.StopOnShutdown(startWorkflowHandler))
+	app.HandleFunc("/status/{id}", statusHandler)
+	outage := &outageRunner{}
+	app.HandleFunc("POST /scenarios/outage", app.StopOnShutdown(outage.startHandler))
+	app.HandleFunc("GET /scenarios/outage", outage.reportHandler)
 
-	// Get port from environment variable or use default
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		appPort = "6009"
-	}
+	app.CheckSidecar(appkit.SidecarRequirements{Workflows: true})
 
-	// Start HTTP server
-	log.Printf("Starting HTTP server on port %s", appPort)
-	log.Fatal(http.ListenAndServe(":"+appPort, nil))
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func TestWorkflow2(ctx *workflow.WorkflowContext) (any, error) {
-	fmt.Println("TestWorkflow2 called")
+// LocalWorkflow2 calls TestActivity2 in this app.
+func LocalWorkflow2(ctx *workflow.WorkflowContext) (any, error) {
 	var number int
-	// err := ctx.CallActivity(TestActivity2).Await(&number)
-	err := ctx.CallActivity("random_number_generator", workflow.WithActivityAppID("workflows-crossapp3"), workflow.WithActivityRetryPolicy(&workflow.RetryPolicy{
-		MaxAttempts:          3,
-		InitialRetryInterval: 100 * time.Millisecond,
-		BackoffCoefficient:   2,
-		MaxRetryInterval:     1 * time.Second,
-	})).Await(&number)
-	if err != nil {
+	if err := ctx.CallActivity(TestActivity2).Await(&number); err != nil {
 		return nil, err
 	}
 	return "Workflow completed with number: " + strconv.Itoa(number), nil
 }
 
 func TestActivity2(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	fmt.Println("TestActivity2 called")
 	return rand.Intn(100000), nil
 }
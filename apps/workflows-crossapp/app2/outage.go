@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/kube"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/acroca/dapr-example-app/lib/go/wfretry"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// outageApp is the app the outage scenario takes down, the default target of
+// TestWorkflow2.
+const outageApp = "workflows-crossapp3"
+
+// RetryAttempt is a failed attempt of a cross-app call, by the workflow clock.
+type RetryAttempt struct {
+	Attempt  int       `json:"attempt"`
+	FailedAt time.Time `json:"failed_at"`
+	Error    string    `json:"error"`
+}
+
+// RetryTimeline is the output of RetryTimelineWorkflow2.
+type RetryTimeline struct {
+	StartedAt time.Time `json:"started_at"`
+	// Attempts are the failed attempts that were retried. The last attempt,
+	// if it fails, isn't retried, so it shows up as Error instead.
+	Attempts   []RetryAttempt `json:"attempts"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Number     int            `json:"number,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// RetryTimelineWorkflow2 calls the activity of the target in its input, like
+// TestWorkflow2, recording every failed attempt the retry policy retries. It
+// completes with the timeline even if the call fails, so it can be checked.
+func RetryTimelineWorkflow2(ctx *workflow.WorkflowContext) (any, error) {
+	var target CrossAppTarget
+	if err := ctx.GetInput(&target); err != nil {
+		return nil, err
+	}
+	if target.Retry == nil {
+		return nil, errors.New("the target has no retry policy")
+	}
+
+	timeline := RetryTimeline{StartedAt: ctx.CurrentTimeUTC()}
+	policy := target.Retry.policy()
+	// The policy asks Handle whether to retry every failed attempt but the
+	// last, at the time the workflow learns of it. It runs again on replay,
+	// rebuilding the same timeline.
+	policy.Handle = func(err error) bool {
+		timeline.Attempts = append(timeline.Attempts, RetryAttempt{
+			Attempt:  len(timeline.Attempts) + 1,
+			FailedAt: ctx.CurrentTimeUTC(),
+			Error:    err.Error(),
+		})
+		return true
+	}
+	err := ctx.CallActivity(target.Activity,
+		workflow.WithActivityAppID(target.AppID),
+		workflow.WithActivityRetryPolicy(policy),
+	).Await(&timeline.Number)
+	if err != nil {
+		timeline.Error = err.Error()
+	}
+	timeline.FinishedAt = ctx.CurrentTimeUTC()
+	return timeline, nil
+}
+
+// retryTolerance is how much longer than the policy's delay a retry can
+// take: the attempt itself, failing against an app that's down, and the
+// timer firing late.
+const retryTolerance = 3 * time.Second
+
+type RetryGap struct {
+	Attempt int   `json:"attempt"`
+	WantMs  int64 `json:"want_ms"`
+	GotMs   int64 `json:"got_ms"`
+	// Capped is whether the delay is MaxRetryInterval, rather than the
+	// backoff, which would be longer.
+	Capped bool `json:"capped,omitempty"`
+}
+
+type OutageReport struct {
+	Status     string         `json:"status"`
+	InstanceID string         `json:"instance_id,omitempty"`
+	Target     CrossAppTarget `json:"target"`
+	OutageMs   int64          `json:"outage_ms"`
+	// DownAt and UpAt are when the target app was scaled down and back up,
+	// by the clock of this app.
+	DownAt   time.Time      `json:"down_at"`
+	UpAt     time.Time      `json:"up_at,omitzero"`
+	Timeline *RetryTimeline `json:"timeline,omitempty"`
+	// Gaps compare the time between failed attempts with the policy's delay
+	// before the next one.
+	Gaps  []RetryGap `json:"gaps,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// checkTimeline checks the retries followed the policy: a failed first
+// attempt, since the call started with the target down, no more attempts
+// than MaxAttempts, all of them if the call failed, and the policy's delay,
+// up to retryTolerance more, between an attempt failing and the next one
+// failing. Past the cap, that's MaxRetryInterval.
+func checkTimeline(report *OutageReport) error {
+	retry, timeline := report.Target.Retry, report.Timeline
+	policy := *retry.policy()
+	// With a single attempt, its failure is the error of the call rather
+	// than a retried attempt.
+	if len(timeline.Attempts) == 0 && timeline.Error == "" {
+		return fmt.Errorf("the call succeeded without a failed attempt, while %s was down", report.Target.AppID)
+	}
+	if len(timeline.Attempts) > retry.MaxAttempts-1 {
+		return fmt.Errorf("expected at most %d retries, got %d", retry.MaxAttempts-1, len(timeline.Attempts))
+	}
+	if timeline.Error != "" && len(timeline.Attempts) != retry.MaxAttempts-1 {
+		return fmt.Errorf("the call failed after %d retries, expected %d: %s", len(timeline.Attempts), retry.MaxAttempts-1, timeline.Error)
+	}
+	for i := 1; i < len(timeline.Attempts); i++ {
+		gap := retryGap(policy, i+1, timeline.Attempts[i-1].FailedAt, timeline.Attempts[i].FailedAt)
+		report.Gaps = append(report.Gaps, gap)
+		if err := gap.check(fmt.Sprintf("attempt %d failed", i+1), i); err != nil {
+			return err
+		}
+	}
+	if n := len(timeline.Attempts); n > 0 && timeline.Error == "" {
+		gap := retryGap(policy, n+1, timeline.Attempts[n-1].FailedAt, timeline.FinishedAt)
+		report.Gaps = append(report.Gaps, gap)
+		if err := gap.check("the call succeeded", n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryGap compares the time between the failure of the attempt before
+// attempt and the end of attempt with the policy's delay.
+func retryGap(policy workflow.RetryPolicy, attempt int, from, to time.Time) RetryGap {
+	want := wfretry.Delay(policy, attempt-2)
+	uncapped := policy
+	uncapped.MaxRetryInterval = 0
+	return RetryGap{
+		Attempt: attempt,
+		WantMs:  want.Milliseconds(),
+		GotMs:   to.Sub(from).Milliseconds(),
+		Capped:  policy.MaxRetryInterval > 0 && wfretry.Delay(uncapped, attempt-2) > want,
+	}
+}
+
+// check fails if the gap is shorter than the delay, or longer by more than
+// retryTolerance. what says what happened after attempt prev failed.
+func (g RetryGap) check(what string, prev int) error {
+	got, want := time.Duration(g.GotMs)*time.Millisecond, time.Duration(g.WantMs)*time.Millisecond
+	switch {
+	case got < want:
+		return fmt.Errorf("%s %s after attempt %d, before the %s retry delay", what, got, prev, want)
+	case got > want+retryTolerance && g.Capped:
+		return fmt.Errorf("%s %s after attempt %d, more than %s past the %s MaxRetryInterval the delay is capped at", what, got, prev, retryTolerance, want)
+	case got > want+retryTolerance:
+		return fmt.Errorf("%s %s after attempt %d, more than %s past the %s retry delay", what, got, prev, retryTolerance, want)
+	}
+	return nil
+}
+
+// waitForPods polls the pods of the app until done, given how many of them
+// are ready, says they settled.
+func waitForPods(ctx context.Context, cluster *kube.Client, app string, done func(ready, total int) bool) error {
+	for {
+		pods, err := cluster.ListPods(ctx, kube.Namespace(), "app="+app)
+		if err != nil {
+			return err
+		}
+		ready := 0
+		for _, p := range pods {
+			if p.Ready() {
+				ready++
+			}
+		}
+		if done(ready, len(pods)) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s pods never settled, %d of %d ready: %w", app, ready, len(pods), ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// runOutage scales outageApp to zero, starts RetryTimelineWorkflow2 against
+// it, scales it back up after the outage and checks the retries of the call.
+func runOutage(ctx context.Context, report *OutageReport, outage time.Duration) error {
+	cluster, err := kube.NewInClusterClient()
+	if err != nil {
+		return err
+	}
+	ns := kube.Namespace()
+
+	report.DownAt = time.Now()
+	if err := cluster.ScaleDeployment(ctx, ns, outageApp, 0); err != nil {
+		return fmt.Errorf("failed to scale down %s: %w", outageApp, err)
+	}
+	// Bring it back whatever happens, the other scenarios need it.
+	defer func() {
+		if report.UpAt.IsZero() {
+			if err := cluster.ScaleDeployment(context.Background(), ns, outageApp, 1); err != nil {
+				log.Printf("Error scaling %s back up: %v", outageApp, err)
+			}
+		}
+	}()
+	if err := waitForPods(ctx, cluster, outageApp, func(_, total int) bool { return total == 0 }); err != nil {
+		return err
+	}
+
+	id, err := wfClient.ScheduleWorkflow(ctx, "RetryTimelineWorkflow2", wfclient.WithInput(report.Target))
+	if err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+	report.InstanceID = id
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(outage):
+	}
+	if err := cluster.ScaleDeployment(ctx, ns, outageApp, 1); err != nil {
+		return fmt.Errorf("failed to scale up %s: %w", outageApp, err)
+	}
+	report.UpAt = time.Now()
+
+	metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to wait for workflow completion: %w", err)
+	}
+	if metadata.Status != wfclient.StatusCompleted {
+		return fmt.Errorf("workflow finished with status %s", metadata.Status)
+	}
+	report.Timeline = &RetryTimeline{}
+	if err := json.Unmarshal([]byte(metadata.Output), report.Timeline); err != nil {
+		return fmt.Errorf("failed to decode workflow output: %w", err)
+	}
+	return checkTimeline(report)
+}
+
+// outageRunner runs the outage scenario in the background, one run at a
+// time, and keeps the report of the last run.
+type outageRunner struct {
+	mu      sync.Mutex
+	running bool
+	report  *OutageReport
+}
+
+// startHandler starts the outage scenario. The outage_ms query parameter sets
+// how long the target stays down, and the optional RetryConfig body the retry
+// policy of the call.
+func (o *outageRunner) startHandler(w http.ResponseWriter, r *http.Request) {
+	outage := 8 * time.Second
+	if q := r.URL.Query().Get("outage_ms"); q != "" {
+		ms, err := strconv.Atoi(q)
+		if err != nil || ms < 0 {
+			http.Error(w, fmt.Sprintf("Invalid outage_ms %q", q), http.StatusBadRequest)
+			return
+		}
+		outage = time.Duration(ms) * time.Millisecond
+	}
+	retry := &RetryConfig{MaxAttempts: 6, InitialRetryIntervalMs: 1000, BackoffCoefficient: 2, MaxRetryIntervalMs: 5000}
+	if r.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(retry); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid retry policy: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := retry.validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid retry policy: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	o.mu.Lock()
+	if o.running {
+		o.mu.Unlock()
+		http.Error(w, "Outage scenario already running", http.StatusConflict)
+		return
+	}
+	o.running = true
+	o.mu.Unlock()
+
+	report := &OutageReport{
+		Target:   CrossAppTarget{AppID: outageApp, Activity: defaultTarget.Activity, Retry: retry},
+		OutageMs: outage.Milliseconds(),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), outage+5*time.Minute)
+		defer cancel()
+		report.Status = "passed"
+		if err := runOutage(ctx, report, outage); err != nil {
+			report.Status = "failed"
+			report.Error = err.Error()
+		}
+		log.Printf("Outage scenario %s", report.Status)
+		o.mu.Lock()
+		o.running = false
+		o.report = report
+		o.mu.Unlock()
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Outage scenario started"))
+}
+
+// reportHandler returns the report of the last outage run.
+func (o *outageRunner) reportHandler(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		http.Error(w, "Outage scenario still running", http.StatusNotFound)
+		return
+	}
+	if o.report == nil {
+		http.Error(w, "Outage scenario hasn't run yet", http.StatusNotFound)
+		return
+	}
+	appkit.WriteJSON(w, http.StatusOK, o.report)
+}
@@ -72,6 +72,25 @@ def start_workflow():
         logger.error(f"Error starting workflow: {str(e)}")
         return jsonify({"error": f"Failed to start workflow: {str(e)}"}), 500
 
+@app.route('/matrix', methods=['POST'])
+def run_matrix():
+    """Run matrix_workflow over the targets in the body and answer with its
+    results, for workflows-crossapp2 to check the calls from Python"""
+    global wfClient
+    if wfClient is None:
+        wfClient = DaprWorkflowClient()
+
+    try:
+        targets = request.get_json()
+        instance_id = wfClient.schedule_new_workflow(workflow=matrix_workflow, input=targets)
+        state = wfClient.wait_for_workflow_completion(instance_id=instance_id, timeout_in_seconds=90)
+        if not state or state.runtime_status.name != 'COMPLETED':
+            return jsonify({"error": f"Matrix workflow {instance_id} didn't complete", "instance_id": instance_id}), 500
+        return state.serialized_output, 200, {'Content-Type': 'application/json'}
+    except Exception as e:
+        logger.error(f"Error running the matrix workflow: {str(e)}")
+        return jsonify({"error": f"Failed to run the matrix workflow: {str(e)}"}), 500
+
 @workflow_runtime.workflow(name=workflow_name)
 def test_workflow(ctx: DaprWorkflowContext, wf_input: str):
     logger.debug(f'Workflow {workflow_name} started. Input: {wf_input}')
@@ -84,6 +103,22 @@ def test_workflow(ctx: DaprWorkflowContext, wf_input: str):
     return "Workflow completed"
 
 
+@workflow_runtime.workflow(name="matrix_workflow")
+def matrix_workflow(ctx: DaprWorkflowContext, targets: list):
+    """Call every target from Python, for the matrix of workflows-crossapp2,
+    and return how each call went"""
+    results = []
+    for target in targets:
+        result = {"from": "workflows-crossapp3", "app_id": target["app_id"], "activity": target["activity"], "status": "passed"}
+        try:
+            result["number"] = yield ctx.call_activity(target["activity"], app_id=target["app_id"])
+        except Exception as e:
+            result["status"] = "failed"
+            result["error"] = str(e)
+        results.append(result)
+    return results
+
+
 @workflow_runtime.activity(name="random_number_generator")
 def random_number_generator(ctx: WorkflowActivityContext):
     logger.debug(f'Random number activity started')
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/task"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// waitingStatus is the custom status WaitForEventsWorkflow sets once it starts
+// waiting, so drivers know when to raise events.
+const waitingStatus = "waiting"
+
+type WaitForEventsInput struct {
+	EventName string `json:"event_name"`
+	Count     int    `json:"count"`
+	// Timeout of each wait, negative to wait forever.
+	Timeout time.Duration `json:"timeout"`
+	// Delay before waiting, so events can be raised before the workflow waits.
+	Delay time.Duration `json:"delay"`
+}
+
+type WaitForEventsOutput struct {
+	Events   []string `json:"events"`
+	TimedOut bool     `json:"timed_out"`
+}
+
+// WaitForEventsWorkflow waits for Count events of the same name, returning
+// their payloads in the order they were received.
+func WaitForEventsWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input WaitForEventsInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	if input.Delay > 0 {
+		if err := ctx.CreateTimer(input.Delay).Await(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx.SetCustomStatus(waitingStatus)
+	var output WaitForEventsOutput
+	for range input.Count {
+		var payload string
+		err := ctx.WaitForExternalEvent(input.EventName, input.Timeout).Await(&payload)
+		if errors.Is(err, task.ErrTaskCanceled) {
+			output.TimedOut = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		output.Events = append(output.Events, payload)
+	}
+	return output, nil
+}
+
+// CrossAppEventScenario waits for events in a child workflow running in
+// another app, returning the child's output.
+func CrossAppEventScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var input WaitForEventsInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	var output WaitForEventsOutput
+	err := ctx.CallChildWorkflow(WaitForEventsWorkflow,
+		workflow.WithChildWorkflowInput(input),
+		workflow.WithChildWorkflowInstanceID(crossAppEventChildID(ctx.ID())),
+		workflow.WithChildWorkflowAppID(crossAppID),
+	).Await(&output)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func crossAppEventChildID(parentID string) string {
+	return parentID + "-events"
+}
+
+// eventScenario starts WaitForEventsWorkflow, raises the given payloads once
+// it's waiting (or right away, when raiseEarly is set), and checks the
+// workflow received exactly the expected output.
+func eventScenario(input WaitForEventsInput, payloads []string, raiseEarly bool, want WaitForEventsOutput) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(WaitForEventsWorkflow), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		if raiseEarly {
+			if _, err := wfClient.WaitForWorkflowStart(ctx, id); err != nil {
+				return fmt.Errorf("failed to wait for workflow start: %w", err)
+			}
+		} else if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+			return err
+		}
+
+		for _, payload := range payloads {
+			if err := wfClient.RaiseEvent(ctx, id, input.EventName, wfclient.WithEventPayload(payload)); err != nil {
+				return fmt.Errorf("failed to raise event: %w", err)
+			}
+		}
+
+		if raiseEarly {
+			// The events only count as raised early if the workflow wasn't
+			// waiting for them yet.
+			metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+			}
+			if metadata.CustomStatus == waitingStatus {
+				return fmt.Errorf("workflow was already waiting when the events were raised")
+			}
+		}
+
+		return expectEventsOutput(ctx, id, want)
+	}
+}
+
+// crossAppEventScenario raises the events from this app, through the
+// /raise-event endpoint of the app running the waiting child workflow.
+func crossAppEventScenario(input WaitForEventsInput, payloads []string, want WaitForEventsOutput) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(CrossAppEventScenario), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		childID := crossAppEventChildID(id)
+		for _, payload := range payloads {
+			req := RaiseEventRequest{InstanceID: childID, EventName: input.EventName, Payload: payload}
+			if err := raiseRemoteEvent(ctx, crossAppID, req); err != nil {
+				return err
+			}
+		}
+
+		return expectEventsOutput(ctx, id, want)
+	}
+}
+
+func expectEventsOutput(ctx context.Context, id string, want WaitForEventsOutput) error {
+	metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to wait for workflow completion: %w", err)
+	}
+	if err := expectCompleted(metadata); err != nil {
+		return err
+	}
+	var got WaitForEventsOutput
+	if err := json.Unmarshal([]byte(metadata.Output), &got); err != nil {
+		return fmt.Errorf("failed to decode workflow output: %w", err)
+	}
+	if got.TimedOut != want.TimedOut || !slices.Equal(got.Events, want.Events) {
+		return fmt.Errorf("expected output %+v, got %+v", want, got)
+	}
+	return nil
+}
+
+type RaiseEventRequest struct {
+	InstanceID string `json:"instance_id"`
+	EventName  string `json:"event_name"`
+	Payload    string `json:"payload"`
+}
+
+// raiseRemoteEvent asks another app to raise an event to one of its workflow
+// instances. It retries until the instance exists there, and while the app or
+// the sidecar fail or can't be reached, but not on the other client errors,
+// which won't go away.
+func raiseRemoteEvent(ctx context.Context, appID string, req RaiseEventRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	for {
+		err := postRaiseEvent(ctx, appID, data)
+		if err == nil {
+			return nil
+		}
+		var status *statusError
+		if errors.As(err, &status) && status.code < http.StatusInternalServerError && status.code != http.StatusNotFound {
+			return fmt.Errorf("failed to raise event on %s: %w", appID, err)
+		}
+		log.Printf("Raising event %s on %s/%s failed, retrying: %v", req.EventName, appID, req.InstanceID, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to raise event on %s: %w", appID, err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// statusError is an error answer of another app.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+// postRaiseEvent posts a RaiseEventRequest to the raise-event method of
+// another app, returning a *statusError if it answers with one.
+func postRaiseEvent(ctx context.Context, appID string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, invokeURL(appID, "raise-event"), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// raiseEventHandler raises an event to a workflow instance of this app, on
+// behalf of other apps. It answers 404 until the instance exists, so callers
+// can retry, and 500 if the instance can't be reached.
+func raiseEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RaiseEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := wfClient.FetchWorkflowMetadata(r.Context(), req.InstanceID); errors.Is(err, wfclient.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Workflow %s not found: %v", req.InstanceID, err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch workflow %s: %v", req.InstanceID, err), http.StatusInternalServerError)
+		return
+	}
+	if err := wfClient.RaiseEvent(r.Context(), req.InstanceID, req.EventName, wfclient.WithEventPayload(req.Payload)); errors.Is(err, wfclient.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Workflow %s not found: %v", req.InstanceID, err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to raise event: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Raised event %s on workflow %s", req.EventName, req.InstanceID)
+	w.WriteHeader(http.StatusOK)
+}
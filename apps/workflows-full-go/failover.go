@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/kube"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+type LongChildInput struct {
+	N     int `json:"n"`
+	Steps int `json:"steps"`
+}
+
+// LongChildWorkflow doubles N once per step, with one DoubleActivity call per
+// step, reporting the steps done so far as its custom status.
+func LongChildWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input LongChildInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	n := input.N
+	for step := range input.Steps {
+		ctx.SetCustomStatus(strconv.Itoa(step))
+		if err := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(n)).Await(&n); err != nil {
+			return nil, err
+		}
+	}
+	ctx.SetCustomStatus(strconv.Itoa(input.Steps))
+	return n, nil
+}
+
+type LongParentInput struct {
+	Child LongChildInput `json:"child"`
+	// AppIDs are the apps to run a child in, one child per app.
+	AppIDs []string `json:"app_ids"`
+}
+
+// LongParentWorkflow runs a LongChildWorkflow in each of the given apps at
+// once, returning their results in the same order.
+func LongParentWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input LongParentInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	tasks := make([]workflow.Task, 0, len(input.AppIDs))
+	for _, appID := range input.AppIDs {
+		tasks = append(tasks, ctx.CallChildWorkflow(LongChildWorkflow,
+			workflow.WithChildWorkflowInput(input.Child),
+			workflow.WithChildWorkflowInstanceID(longChildID(ctx.ID(), appID)),
+			workflow.WithChildWorkflowAppID(appID),
+		))
+	}
+	results := make([]int, 0, len(tasks))
+	for _, t := range tasks {
+		var n int
+		if err := t.Await(&n); err != nil {
+			return nil, err
+		}
+		results = append(results, n)
+	}
+	return results, nil
+}
+
+func longChildID(parentID, appID string) string {
+	return parentID + "-" + appID
+}
+
+// startLongParent starts LongParentWorkflow and returns its instance ID.
+func startLongParent(ctx context.Context, result *ScenarioResult, input LongParentInput) (string, error) {
+	id, err := wfClient.ScheduleWorkflow(ctx, workflowName(LongParentWorkflow), wfclient.WithInput(input))
+	if err != nil {
+		return "", fmt.Errorf("failed to start workflow: %w", err)
+	}
+	result.setInstanceID(id)
+	return id, nil
+}
+
+// expectLongResults waits for LongParentWorkflow to complete and checks every
+// child, fetched from the app it ran in, completed with the expected result.
+func expectLongResults(ctx context.Context, id string, input LongParentInput) error {
+	want := input.Child.N << input.Child.Steps
+	metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to wait for workflow completion: %w", err)
+	}
+	if err := expectCompleted(metadata); err != nil {
+		return err
+	}
+	var results []int
+	if err := json.Unmarshal([]byte(metadata.Output), &results); err != nil {
+		return fmt.Errorf("failed to decode workflow output: %w", err)
+	}
+	if len(results) != len(input.AppIDs) || slices.ContainsFunc(results, func(n int) bool { return n != want }) {
+		return fmt.Errorf("expected %d from each of %v, got %v", want, input.AppIDs, results)
+	}
+
+	for _, appID := range input.AppIDs {
+		child, err := remoteStatus(ctx, appID, longChildID(id, appID))
+		if err != nil {
+			return fmt.Errorf("failed to fetch child workflow status from %s: %w", appID, err)
+		}
+		if child.RuntimeStatus != wfclient.StatusCompleted || child.Output != strconv.Itoa(want) {
+			return fmt.Errorf("expected child in %s to complete with %d, got %s %s", appID, want, child.RuntimeStatus, child.Output)
+		}
+	}
+	return nil
+}
+
+// routingScenario runs a child in each of the other two apps at once, and
+// checks each child only exists in the app it was sent to.
+func routingScenario(ctx context.Context, result *ScenarioResult) error {
+	input := LongParentInput{
+		Child:  LongChildInput{N: 1, Steps: 2},
+		AppIDs: []string{crossAppID, routingAppID},
+	}
+	id, err := startLongParent(ctx, result, input)
+	if err != nil {
+		return err
+	}
+	if err := expectLongResults(ctx, id, input); err != nil {
+		return err
+	}
+
+	for _, appID := range input.AppIDs {
+		for _, other := range input.AppIDs {
+			if other == appID {
+				continue
+			}
+			_, err := remoteStatus(ctx, other, longChildID(id, appID))
+			if err == nil {
+				return fmt.Errorf("child sent to %s also exists in %s", appID, other)
+			}
+			if !errors.Is(err, wfclient.ErrNotFound) {
+				return fmt.Errorf("failed to fetch child workflow status from %s: %w", other, err)
+			}
+		}
+	}
+	return nil
+}
+
+type FailoverReport struct {
+	KilledPod      string `json:"killed_pod"`
+	KilledAtStep   int    `json:"killed_at_step"`
+	ReplacementPod string `json:"replacement_pod,omitempty"`
+	// RecoveryMs is the time from deleting the pod until its replacement is
+	// ready.
+	RecoveryMs int64 `json:"recovery_ms,omitempty"`
+}
+
+// failoverScenario runs a long child in crossAppID and deletes that app's pod
+// once the child is halfway through. The child has to resume in the
+// replacement pod, and the parent to complete with the right result.
+func failoverScenario(ctx context.Context, result *ScenarioResult) error {
+	cluster, err := kube.NewInClusterClient()
+	if err != nil {
+		return err
+	}
+	namespace := kube.Namespace()
+	selector := "app=" + crossAppID
+
+	input := LongParentInput{
+		Child:  LongChildInput{N: 1, Steps: 20},
+		AppIDs: []string{crossAppID},
+	}
+	id, err := startLongParent(ctx, result, input)
+	if err != nil {
+		return err
+	}
+
+	childID := longChildID(id, crossAppID)
+	var step int
+	err = poll(ctx, fmt.Sprintf("%s/%s never got halfway", crossAppID, childID), func() (bool, error) {
+		status, err := remoteStatus(ctx, crossAppID, childID)
+		if errors.Is(err, wfclient.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		step, _ = strconv.Atoi(status.CustomStatus)
+		return step >= input.Child.Steps/2, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	pods, err := cluster.ListPods(ctx, namespace, selector)
+	if err != nil {
+		return fmt.Errorf("failed to list pods of %s: %w", crossAppID, err)
+	}
+	if len(pods) != 1 {
+		return fmt.Errorf("expected one pod of %s, found %d", crossAppID, len(pods))
+	}
+	report := FailoverReport{KilledPod: pods[0].Metadata.Name, KilledAtStep: step}
+	result.Details = &report
+
+	log.Printf("Deleting pod %s at step %d of %s", report.KilledPod, step, childID)
+	killedAt := time.Now()
+	if err := cluster.KillPod(ctx, namespace, report.KilledPod); err != nil {
+		return fmt.Errorf("failed to delete pod %s: %w", report.KilledPod, err)
+	}
+	err = poll(ctx, fmt.Sprintf("%s was never replaced", report.KilledPod), func() (bool, error) {
+		pods, err := cluster.ListPods(ctx, namespace, selector)
+		if err != nil {
+			return false, err
+		}
+		for _, p := range pods {
+			if p.Metadata.Name != report.KilledPod && p.Ready() {
+				report.ReplacementPod = p.Metadata.Name
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	report.RecoveryMs = time.Since(killedAt).Milliseconds()
+
+	return expectLongResults(ctx, id, input)
+}
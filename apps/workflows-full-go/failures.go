@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+	"github.com/acroca/dapr-example-app/lib/go/wfretry"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// conformanceError is what the failing activities and workflows return, so
+// the scenarios can recognize its type wherever it's reported.
+type conformanceError struct {
+	msg string
+}
+
+func (e *conformanceError) Error() string {
+	return e.msg
+}
+
+// conformanceErrorType is how the SDK reports conformanceError in
+// FailureDetails.
+const conformanceErrorType = "*main.conformanceError"
+
+// FailingActivity always fails with the message it gets as input.
+func FailingActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var msg string
+	ctx.GetInput(&msg)
+	return nil, &conformanceError{msg: msg}
+}
+
+// FailingWorkflow always fails with the message it gets as input.
+func FailingWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var msg string
+	if err := ctx.GetInput(&msg); err != nil {
+		return nil, err
+	}
+	return nil, &conformanceError{msg: msg}
+}
+
+type FailureInput struct {
+	// Target is what fails: "activity", "child" or "cross-app-child".
+	Target  string `json:"target"`
+	Message string `json:"message"`
+}
+
+// FailurePropagationWorkflow calls a target that fails, checks the failure
+// message reached it, and fails itself wrapping that failure.
+func FailurePropagationWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input FailureInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch input.Target {
+	case "activity":
+		err = ctx.CallActivity(FailingActivity, workflow.WithActivityInput(input.Message)).Await(nil)
+	case "child":
+		err = ctx.CallChildWorkflow(FailingWorkflow,
+			workflow.WithChildWorkflowInput(input.Message),
+			workflow.WithChildWorkflowInstanceID(failingChildID(ctx.ID())),
+		).Await(nil)
+	case "cross-app-child":
+		err = ctx.CallChildWorkflow(FailingWorkflow,
+			workflow.WithChildWorkflowInput(input.Message),
+			workflow.WithChildWorkflowInstanceID(failingChildID(ctx.ID())),
+			workflow.WithChildWorkflowAppID(crossAppID),
+		).Await(nil)
+	default:
+		return nil, fmt.Errorf("unknown failure target %q", input.Target)
+	}
+
+	if err == nil {
+		return nil, fmt.Errorf("expected %s to fail", input.Target)
+	}
+	if !strings.Contains(err.Error(), input.Message) {
+		return nil, fmt.Errorf("expected %s failure to contain %q, got %q", input.Target, input.Message, err.Error())
+	}
+	return nil, fmt.Errorf("%s failed: %w", input.Target, err)
+}
+
+func failingChildID(parentID string) string {
+	return parentID + "-failing"
+}
+
+// FailureReport holds the failure details as seen by the client, for the
+// parent and, when the target is a child workflow, for the child.
+type FailureReport struct {
+	Parent *wfclient.FailureDetails `json:"parent"`
+	// InnerFailure is "preserved" if the parent's failure details keep the
+	// failure of the target as their inner failure, or says why not. The
+	// Go SDK never sets one, a known gap the scenario reports rather than
+	// fails on.
+	InnerFailure string                   `json:"inner_failure,omitempty"`
+	Child        *wfclient.FailureDetails `json:"child,omitempty"`
+	History      string                   `json:"history,omitempty"`
+}
+
+// failureScenario runs FailurePropagationWorkflow against the given target,
+// checking the failure reaches the client with the original message: the
+// parent's failure message has the message of the target's failure. The
+// child's own status, and the TaskFailed history event for activities, have
+// to keep the original type too, and so does the parent's inner failure if
+// there is one.
+//
+// The Go SDK turns a failed task into a plain error when awaiting it, so the
+// parent has no inner failure: that's reported in the details, not failed on.
+func failureScenario(target string) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		input := FailureInput{Target: target, Message: "conformance failure from " + target}
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(FailurePropagationWorkflow), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		if metadata.Status != wfclient.StatusFailed {
+			return fmt.Errorf("expected workflow to fail, got %s", metadata.Status)
+		}
+		report := FailureReport{Parent: metadata.FailureDetails}
+		result.Details = &report
+		if report.Parent == nil {
+			return fmt.Errorf("failed workflow has no failure details")
+		}
+		want := target + " failed: "
+		if !strings.HasPrefix(report.Parent.ErrorMessage, want) || !strings.Contains(report.Parent.ErrorMessage, input.Message) {
+			return fmt.Errorf("expected failure message %q to start with %q and contain %q", report.Parent.ErrorMessage, want, input.Message)
+		}
+
+		// Without history, the activity's failure can't be checked: the rest
+		// still is, and the scenario is skipped if it passes.
+		var skip error
+		switch target {
+		case "activity":
+			report.History, err = expectFailedTaskHistory(ctx, id, input.Message)
+			if isSkipped(err) {
+				skip = err
+			} else if err != nil {
+				return err
+			}
+		case "child":
+			child, err := wfClient.FetchWorkflowMetadata(ctx, failingChildID(id))
+			if err != nil {
+				return fmt.Errorf("failed to fetch child workflow metadata: %w", err)
+			}
+			report.Child = child.FailureDetails
+		case "cross-app-child":
+			child, err := remoteStatus(ctx, crossAppID, failingChildID(id))
+			if err != nil {
+				return fmt.Errorf("failed to fetch child workflow status: %w", err)
+			}
+			report.Child = child.FailureDetails
+		}
+		if target != "activity" {
+			if err := expectConformanceFailure("child", report.Child, input.Message); err != nil {
+				return err
+			}
+		}
+
+		if report.Parent.InnerFailure == nil {
+			report.InnerFailure = "not preserved: the Go SDK awaits a failed task as a plain error, without its failure details"
+			return skip
+		}
+		report.InnerFailure = "preserved"
+		if err := expectConformanceFailure("inner", report.Parent.InnerFailure, input.Message); err != nil {
+			return err
+		}
+		return skip
+	}
+}
+
+// expectConformanceFailure checks details, described by what in errors, are
+// those of a conformanceError with the given message.
+func expectConformanceFailure(what string, details *wfclient.FailureDetails, msg string) error {
+	if details == nil {
+		return fmt.Errorf("%s failure has no details", what)
+	}
+	if details.ErrorType != conformanceErrorType || details.ErrorMessage != msg {
+		return fmt.Errorf("expected %s failure %s %q, got %s %q", what, conformanceErrorType, msg, details.ErrorType, details.ErrorMessage)
+	}
+	return nil
+}
+
+// expectFailedTaskHistory checks the history of the instance has a TaskFailed
+// event with the original error type and message.
+func expectFailedTaskHistory(ctx context.Context, id, msg string) (string, error) {
+	history, err := expectHistory(ctx, id, "TaskScheduled", "TaskFailed", "ExecutionCompleted")
+	if history != "ok" || err != nil {
+		return history, err
+	}
+	events, err := wfhistory.Fetch(ctx, daprClient.GrpcClientConn(), id)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range events {
+		if failed := e.GetTaskFailed(); failed != nil {
+			return "ok", expectConformanceFailure("activity", toFailureDetails(failed.GetFailureDetails()), msg)
+		}
+	}
+	return "", fmt.Errorf("history of %s has no TaskFailed event", id)
+}
+
+// The retry policy of the retry scenarios. The delays grow 500ms, 1s, 1.5s
+// (capped), 1.5s...
+const (
+	retryInitialInterval = 500 * time.Millisecond
+	retryBackoff         = 2
+	retryMaxInterval     = 1500 * time.Millisecond
+)
+
+// retryPolicy is the retry policy of the retry scenarios, with the given
+// number of attempts.
+func retryPolicy(maxAttempts int) *workflow.RetryPolicy {
+	return &workflow.RetryPolicy{
+		MaxAttempts:          maxAttempts,
+		InitialRetryInterval: retryInitialInterval,
+		BackoffCoefficient:   retryBackoff,
+		MaxRetryInterval:     retryMaxInterval,
+	}
+}
+
+// attempts records when each attempt of FlakyActivity started, by key. The
+// activity runs in this app, so the scenario can read them afterwards.
+var attempts = struct {
+	sync.Mutex
+	byKey map[string][]time.Time
+}{byKey: map[string][]time.Time{}}
+
+type FlakyInput struct {
+	Key      string `json:"key"`
+	Failures int    `json:"failures"`
+}
+
+// FlakyActivity fails the first Failures attempts for its key, and succeeds
+// after that.
+func FlakyActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var input FlakyInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	attempts.Lock()
+	attempts.byKey[input.Key] = append(attempts.byKey[input.Key], time.Now())
+	attempt := len(attempts.byKey[input.Key])
+	attempts.Unlock()
+
+	if attempt <= input.Failures {
+		log.Printf("Flaky activity %s failing attempt %d", input.Key, attempt)
+		return nil, &conformanceError{msg: fmt.Sprintf("attempt %d failed", attempt)}
+	}
+	return attempt, nil
+}
+
+type RetryInput struct {
+	Failures    int `json:"failures"`
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// RetryWorkflow calls FlakyActivity under the retry policy, returning the
+// attempt that succeeded.
+func RetryWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input RetryInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	var attempt int
+	err := ctx.CallActivity(FlakyActivity,
+		workflow.WithActivityInput(FlakyInput{Key: ctx.ID(), Failures: input.Failures}),
+		workflow.WithActivityRetryPolicy(retryPolicy(input.MaxAttempts)),
+	).Await(&attempt)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+type RetryReport struct {
+	Attempts int `json:"attempts"`
+	// IntervalsMs is the time between the start of each attempt and the next.
+	IntervalsMs []int64 `json:"intervals_ms"`
+	// ExpectedMs is the delay the retry policy should wait after each failure.
+	ExpectedMs []int64 `json:"expected_ms"`
+}
+
+// retryScenario runs RetryWorkflow and checks the activity was attempted as
+// many times as the policy allows, each retry waiting at least the backoff
+// delay and no more than timerTolerance past it (plus the attempt itself).
+func retryScenario(input RetryInput) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(RetryWorkflow), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+
+		attempts.Lock()
+		started := attempts.byKey[id]
+		delete(attempts.byKey, id)
+		attempts.Unlock()
+
+		report := RetryReport{Attempts: len(started)}
+		policy := retryPolicy(input.MaxAttempts)
+		for i := 1; i < len(started); i++ {
+			report.IntervalsMs = append(report.IntervalsMs, started[i].Sub(started[i-1]).Milliseconds())
+			report.ExpectedMs = append(report.ExpectedMs, wfretry.Delay(*policy, i-1).Milliseconds())
+		}
+		result.Details = report
+
+		wantAttempts := min(input.Failures+1, input.MaxAttempts)
+		if input.Failures < input.MaxAttempts {
+			if err := expectCompleted(metadata); err != nil {
+				return err
+			}
+		} else {
+			if metadata.Status != wfclient.StatusFailed {
+				return fmt.Errorf("expected workflow to fail after %d attempts, got %s", input.MaxAttempts, metadata.Status)
+			}
+			want := fmt.Sprintf("attempt %d failed", input.MaxAttempts)
+			if metadata.FailureDetails == nil || !strings.Contains(metadata.FailureDetails.ErrorMessage, want) {
+				return fmt.Errorf("expected the failure of the last attempt, %q, to reach the client", want)
+			}
+		}
+		if report.Attempts != wantAttempts {
+			return fmt.Errorf("expected %d attempts, got %d", wantAttempts, report.Attempts)
+		}
+
+		for i, interval := range report.IntervalsMs {
+			expected := report.ExpectedMs[i]
+			if interval < expected {
+				return fmt.Errorf("retry %d started after %dms, before the %dms backoff", i+1, interval, expected)
+			}
+			if interval > expected+timerTolerance.Milliseconds() {
+				return fmt.Errorf("retry %d started after %dms, more than %s past the %dms backoff", i+1, interval, timerTolerance, expected)
+			}
+		}
+		return nil
+	}
+}
@@ -5,8 +5,10 @@ go 1.24.6
 toolchain go1.24.8
 
 require (
+	github.com/acroca/dapr-example-app/lib/go v0.0.0-00010101000000-000000000000
 	github.com/dapr/durabletask-go v0.10.1
 	github.com/dapr/go-sdk v1.13.0
+	github.com/google/uuid v1.6.0
 )
 
 require (
@@ -15,7 +17,6 @@ require (
 	github.com/dapr/kit v0.16.1 // indirect
 	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	go.opentelemetry.io/otel v1.36.0 // indirect
 	go.opentelemetry.io/otel/metric v1.36.0 // indirect
@@ -28,3 +29,5 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/acroca/dapr-example-app/lib/go => ../../lib/go
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+)
+
+// expectHistory checks the history of the instance contains the given event
+// types in that order, not necessarily next to each other. It returns the
+// outcome to report in the scenario details: "ok", or "unsupported" if the
+// sidecar can't return history, with an error skipping the scenario.
+func expectHistory(ctx context.Context, id string, types ...string) (string, error) {
+	events, err := wfhistory.Fetch(ctx, daprClient.GrpcClientConn(), id)
+	if errors.Is(err, wfhistory.ErrUnsupported) {
+		return "unsupported", skipped("can't check the history of %s: %v", id, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	next := 0
+	for _, e := range events {
+		if next < len(types) && wfhistory.EventType(e) == types[next] {
+			next++
+		}
+	}
+	if next < len(types) {
+		return "", fmt.Errorf("history of %s is missing %s after %v", id, types[next], types[:next])
+	}
+	return "ok", nil
+}
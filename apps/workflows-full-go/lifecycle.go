@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// LifecycleParentWorkflow starts two children that wait for an event forever,
+// one in this app and one in crossAppID, and then waits for both of them.
+func LifecycleParentWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	input := WaitForEventsInput{EventName: "finish", Count: 1, Timeout: -1}
+	local := ctx.CallChildWorkflow(WaitForEventsWorkflow,
+		workflow.WithChildWorkflowInput(input),
+		workflow.WithChildWorkflowInstanceID(localChildID(ctx.ID())),
+	)
+	remote := ctx.CallChildWorkflow(WaitForEventsWorkflow,
+		workflow.WithChildWorkflowInput(input),
+		workflow.WithChildWorkflowInstanceID(remoteChildID(ctx.ID())),
+		workflow.WithChildWorkflowAppID(crossAppID),
+	)
+	ctx.SetCustomStatus(waitingStatus)
+
+	if err := local.Await(nil); err != nil {
+		return nil, err
+	}
+	if err := remote.Await(nil); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func localChildID(parentID string) string {
+	return parentID + "-local"
+}
+
+func remoteChildID(parentID string) string {
+	return parentID + "-remote"
+}
+
+// poll calls check every 100ms until it reports done, returns an error or the
+// context is done.
+func poll(ctx context.Context, what string, check func() (bool, error)) error {
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", what, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func waitForStatus(ctx context.Context, id string, want wfclient.Status) error {
+	return poll(ctx, fmt.Sprintf("%s never reached %s", id, want), func() (bool, error) {
+		metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+		if err != nil {
+			return false, fmt.Errorf("failed to fetch metadata of %s: %w", id, err)
+		}
+		return metadata.Status == want, nil
+	})
+}
+
+func waitForRemoteStatus(ctx context.Context, appID, id string, want wfclient.Status, customStatus string) error {
+	return poll(ctx, fmt.Sprintf("%s/%s never reached %s", appID, id, want), func() (bool, error) {
+		status, err := remoteStatus(ctx, appID, id)
+		if errors.Is(err, wfclient.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return status.RuntimeStatus == want && (customStatus == "" || status.CustomStatus == customStatus), nil
+	})
+}
+
+func waitForPurged(ctx context.Context, id string) error {
+	return poll(ctx, fmt.Sprintf("%s was never purged", id), func() (bool, error) {
+		_, err := wfClient.FetchWorkflowMetadata(ctx, id)
+		if errors.Is(err, wfclient.ErrNotFound) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+func waitForRemotePurged(ctx context.Context, appID, id string) error {
+	return poll(ctx, fmt.Sprintf("%s/%s was never purged", appID, id), func() (bool, error) {
+		_, err := remoteStatus(ctx, appID, id)
+		if errors.Is(err, wfclient.ErrNotFound) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// startLifecycleTree starts LifecycleParentWorkflow and waits until the
+// parent and both children are waiting.
+func startLifecycleTree(ctx context.Context, result *ScenarioResult) (string, error) {
+	id, err := wfClient.ScheduleWorkflow(ctx, workflowName(LifecycleParentWorkflow))
+	if err != nil {
+		return "", fmt.Errorf("failed to start workflow: %w", err)
+	}
+	result.setInstanceID(id)
+
+	if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+		return "", err
+	}
+	if err := waitForCustomStatus(ctx, localChildID(id), waitingStatus); err != nil {
+		return "", err
+	}
+	if err := waitForRemoteStatus(ctx, crossAppID, remoteChildID(id), wfclient.StatusRunning, waitingStatus); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// suspendResumeScenario suspends a waiting workflow, raises the event it waits
+// for while suspended, and checks it only gets processed after resuming.
+func suspendResumeScenario(ctx context.Context, result *ScenarioResult) error {
+	input := WaitForEventsInput{EventName: "approval", Count: 1, Timeout: -1}
+	id, err := wfClient.ScheduleWorkflow(ctx, workflowName(WaitForEventsWorkflow), wfclient.WithInput(input))
+	if err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+	result.setInstanceID(id)
+	if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+		return err
+	}
+
+	if err := wfClient.SuspendWorkflow(ctx, id, "lifecycle scenario"); err != nil {
+		return fmt.Errorf("failed to suspend workflow: %w", err)
+	}
+	if err := waitForStatus(ctx, id, wfclient.StatusSuspended); err != nil {
+		return err
+	}
+
+	if err := wfClient.RaiseEvent(ctx, id, input.EventName, wfclient.WithEventPayload("while-suspended")); err != nil {
+		return fmt.Errorf("failed to raise event while suspended: %w", err)
+	}
+	time.Sleep(2 * time.Second)
+	metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+	}
+	if metadata.Status != wfclient.StatusSuspended {
+		return fmt.Errorf("expected workflow to stay SUSPENDED after raising an event, got %s", metadata.Status)
+	}
+
+	if err := wfClient.ResumeWorkflow(ctx, id, "lifecycle scenario"); err != nil {
+		return fmt.Errorf("failed to resume workflow: %w", err)
+	}
+	if err := expectEventsOutput(ctx, id, WaitForEventsOutput{Events: []string{"while-suspended"}}); err != nil {
+		return err
+	}
+
+	history, err := expectHistory(ctx, id, "ExecutionSuspended", "EventRaised", "ExecutionResumed", "ExecutionCompleted")
+	result.Details = map[string]string{"history": history}
+	return err
+}
+
+// suspendResumeCrossAppScenario suspends a child waiting in crossAppID,
+// through that app, raises the event it waits for while suspended, and
+// checks neither the child nor its parent move on until the child resumes.
+func suspendResumeCrossAppScenario(ctx context.Context, result *ScenarioResult) error {
+	input := WaitForEventsInput{EventName: "approval", Count: 1, Timeout: -1}
+	id, err := wfClient.ScheduleWorkflow(ctx, workflowName(CrossAppEventScenario), wfclient.WithInput(input))
+	if err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+	result.setInstanceID(id)
+	childID := crossAppEventChildID(id)
+	if err := waitForRemoteStatus(ctx, crossAppID, childID, wfclient.StatusRunning, waitingStatus); err != nil {
+		return err
+	}
+
+	if err := remoteLifecycle(ctx, crossAppID, "suspend", childID); err != nil {
+		return err
+	}
+	if err := waitForRemoteStatus(ctx, crossAppID, childID, wfclient.StatusSuspended, ""); err != nil {
+		return err
+	}
+
+	req := RaiseEventRequest{InstanceID: childID, EventName: input.EventName, Payload: "while-suspended"}
+	if err := raiseRemoteEvent(ctx, crossAppID, req); err != nil {
+		return err
+	}
+	time.Sleep(2 * time.Second)
+	child, err := remoteStatus(ctx, crossAppID, childID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch child workflow status: %w", err)
+	}
+	if child.RuntimeStatus != wfclient.StatusSuspended {
+		return fmt.Errorf("expected child to stay SUSPENDED after raising an event, got %s", child.RuntimeStatus)
+	}
+	parent, err := wfClient.FetchWorkflowMetadata(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+	}
+	if parent.Status != wfclient.StatusRunning {
+		return fmt.Errorf("expected parent to keep RUNNING while its child is suspended, got %s", parent.Status)
+	}
+
+	if err := remoteLifecycle(ctx, crossAppID, "resume", childID); err != nil {
+		return err
+	}
+	return expectEventsOutput(ctx, id, WaitForEventsOutput{Events: []string{"while-suspended"}})
+}
+
+// lifecycleHandler applies a lifecycle operation to a workflow instance of
+// this app, on behalf of other apps.
+func lifecycleHandler(operation string, apply func(ctx context.Context, id, reason string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if err := apply(r.Context(), id, "requested by another app"); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to %s workflow %s: %v", operation, id, err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Workflow %s: %s", id, operation)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// remoteLifecycle asks another app to apply a lifecycle operation, suspend or
+// resume, to one of its workflow instances.
+func remoteLifecycle(ctx context.Context, appID, operation, id string) error {
+	if _, err := daprClient.InvokeMethod(ctx, appID, operation+"/"+id, http.MethodPost); err != nil {
+		return fmt.Errorf("failed to %s %s/%s: %w", operation, appID, id, err)
+	}
+	return nil
+}
+
+// terminateScenario terminates a parent recursively and checks both of its
+// children got terminated too.
+func terminateScenario(ctx context.Context, result *ScenarioResult) error {
+	id, err := startLifecycleTree(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	if err := wfClient.TerminateWorkflow(ctx, id, wfclient.WithRecursiveTerminate(true), wfclient.WithOutput("terminated")); err != nil {
+		return fmt.Errorf("failed to terminate workflow: %w", err)
+	}
+	if err := waitForStatus(ctx, id, wfclient.StatusTerminated); err != nil {
+		return err
+	}
+	if err := waitForStatus(ctx, localChildID(id), wfclient.StatusTerminated); err != nil {
+		return err
+	}
+	if err := waitForRemoteStatus(ctx, crossAppID, remoteChildID(id), wfclient.StatusTerminated, ""); err != nil {
+		return err
+	}
+
+	history, err := expectHistory(ctx, id, "ExecutionStarted", "SubOrchestrationInstanceCreated", "ExecutionTerminated")
+	result.Details = map[string]string{"history": history}
+	return err
+}
+
+// purgeScenario purges a terminated parent recursively and checks neither the
+// parent nor its children can be found afterwards.
+func purgeScenario(ctx context.Context, result *ScenarioResult) error {
+	id, err := startLifecycleTree(ctx, result)
+	if err != nil {
+		return err
+	}
+	if err := wfClient.TerminateWorkflow(ctx, id, wfclient.WithRecursiveTerminate(true)); err != nil {
+		return fmt.Errorf("failed to terminate workflow: %w", err)
+	}
+	if err := waitForStatus(ctx, id, wfclient.StatusTerminated); err != nil {
+		return err
+	}
+	if err := waitForStatus(ctx, localChildID(id), wfclient.StatusTerminated); err != nil {
+		return err
+	}
+	if err := waitForRemoteStatus(ctx, crossAppID, remoteChildID(id), wfclient.StatusTerminated, ""); err != nil {
+		return err
+	}
+
+	if err := wfClient.PurgeWorkflow(ctx, id, wfclient.WithRecursivePurge(true)); err != nil {
+		return fmt.Errorf("failed to purge workflow: %w", err)
+	}
+	if err := waitForPurged(ctx, id); err != nil {
+		return err
+	}
+	if err := waitForPurged(ctx, localChildID(id)); err != nil {
+		return err
+	}
+	return waitForRemotePurged(ctx, crossAppID, remoteChildID(id))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// The ways NonDeterministicWorkflow changes its actions on replay.
+const (
+	// ndActivityToTimer calls an activity, and creates a timer instead on
+	// replay.
+	ndActivityToTimer = "activity-to-timer"
+	// ndActivityToChild calls an activity, and a child workflow instead on
+	// replay.
+	ndActivityToChild = "activity-to-child"
+	// ndReordered calls an activity and a child workflow, in the opposite
+	// order on replay.
+	ndReordered = "reordered"
+)
+
+// NonDeterministicWorkflow takes different actions on replay than it did on
+// its first execution. Branching on IsReplaying stands in for branching on
+// the time, random values or anything else that changes between executions,
+// but it's guaranteed to take the other branch on replay.
+func NonDeterministicWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var mode string
+	if err := ctx.GetInput(&mode); err != nil {
+		return nil, err
+	}
+
+	activity := func() workflow.Task {
+		return ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(1))
+	}
+	child := func() workflow.Task {
+		return ctx.CallChildWorkflow(WaitForEventsWorkflow, workflow.WithChildWorkflowInput(WaitForEventsInput{EventName: "never", Count: 1}))
+	}
+
+	var tasks []workflow.Task
+	switch {
+	case !ctx.IsReplaying() && mode == ndReordered:
+		tasks = []workflow.Task{activity(), child()}
+	case !ctx.IsReplaying():
+		tasks = []workflow.Task{activity()}
+	case mode == ndActivityToTimer:
+		tasks = []workflow.Task{ctx.CreateTimer(time.Second)}
+	case mode == ndActivityToChild:
+		tasks = []workflow.Task{child()}
+	case mode == ndReordered:
+		tasks = []workflow.Task{child(), activity()}
+	default:
+		return nil, fmt.Errorf("unknown non-determinism mode %q", mode)
+	}
+	for _, t := range tasks {
+		if err := t.Await(nil); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// NonDeterminismReport holds the error the instance failed with, and how many
+// of each action the history recorded.
+type NonDeterminismReport struct {
+	Error   string         `json:"error,omitempty"`
+	History string         `json:"history"`
+	Actions map[string]int `json:"actions,omitempty"`
+}
+
+// nonDeterminismScenario runs NonDeterministicWorkflow in the given mode and
+// checks the runtime fails it with a non-determinism error. When the history
+// is available, it also checks it only has the actions of the first
+// execution, so the replay didn't leak into it.
+func nonDeterminismScenario(mode string, actions map[string]int) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(NonDeterministicWorkflow), wfclient.WithInput(mode))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		report := &NonDeterminismReport{}
+		result.Details = report
+		if metadata.FailureDetails != nil {
+			report.Error = metadata.FailureDetails.ErrorMessage
+		}
+		if metadata.Status != wfclient.StatusFailed {
+			return fmt.Errorf("expected the runtime to fail the workflow, got %s", metadata.Status)
+		}
+		if !isNonDeterminismError(report.Error) {
+			return fmt.Errorf("expected a non-determinism error, got %q", report.Error)
+		}
+
+		events, err := wfhistory.Fetch(ctx, daprClient.GrpcClientConn(), id)
+		if errors.Is(err, wfhistory.ErrUnsupported) {
+			report.History = "unsupported"
+			return skipped("can't check the actions in the history of %s: %v", id, err)
+		}
+		if err != nil {
+			return err
+		}
+		report.History = "ok"
+		report.Actions = map[string]int{}
+		for _, e := range events {
+			switch t := wfhistory.EventType(e); t {
+			case "TaskScheduled", "TimerCreated", "SubOrchestrationInstanceCreated":
+				report.Actions[t]++
+			}
+		}
+		for t, want := range actions {
+			if report.Actions[t] != want {
+				return fmt.Errorf("expected %d %s events in the history, got %d", want, t, report.Actions[t])
+			}
+		}
+		for t, got := range report.Actions {
+			if actions[t] == 0 {
+				return fmt.Errorf("expected no %s events in the history, got %d", t, got)
+			}
+		}
+		return nil
+	}
+}
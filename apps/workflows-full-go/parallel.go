@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+type ParallelInput struct {
+	Count int           `json:"count"`
+	Sleep time.Duration `json:"sleep"`
+	// AppID runs the fan-out in a child workflow of that app instead.
+	AppID string `json:"app_id,omitempty"`
+}
+
+// ActivitySpan is when an activity started and ended, by the clock of the app
+// that ran it.
+type ActivitySpan struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SpanActivity sleeps for the duration it gets as input, returning when it
+// started and ended.
+func SpanActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var sleep time.Duration
+	ctx.GetInput(&sleep)
+	span := ActivitySpan{Start: time.Now()}
+	time.Sleep(sleep)
+	span.End = time.Now()
+	return span, nil
+}
+
+// ParallelActivitiesWorkflow schedules Count SpanActivity calls at once and
+// returns their spans. It doesn't judge them: the results come from the
+// activities, so they're the same on every replay, and the scenario checks
+// them from outside the workflow.
+func ParallelActivitiesWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input ParallelInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+
+	var spans []ActivitySpan
+	if input.AppID != "" {
+		err := ctx.CallChildWorkflow(ParallelActivitiesWorkflow,
+			workflow.WithChildWorkflowInput(ParallelInput{Count: input.Count, Sleep: input.Sleep}),
+			workflow.WithChildWorkflowAppID(input.AppID),
+		).Await(&spans)
+		if err != nil {
+			return nil, err
+		}
+		return spans, nil
+	}
+
+	tasks := make([]workflow.Task, 0, input.Count)
+	for range input.Count {
+		tasks = append(tasks, ctx.CallActivity(SpanActivity, workflow.WithActivityInput(input.Sleep)))
+	}
+	for _, t := range tasks {
+		var span ActivitySpan
+		if err := t.Await(&span); err != nil {
+			return nil, err
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+type ParallelReport struct {
+	Activities int `json:"activities"`
+	// MaxConcurrency is the most activities that were running at once.
+	MaxConcurrency int `json:"max_concurrency"`
+	// SpreadMs is the time from the first activity start to the last one.
+	SpreadMs int64 `json:"spread_ms"`
+	// TotalMs is the time from the first activity start to the last end.
+	TotalMs int64 `json:"total_ms"`
+}
+
+// maxConcurrency returns how many of the spans overlapped at the busiest
+// moment. A span ending exactly when another starts doesn't overlap it.
+func maxConcurrency(spans []ActivitySpan) int {
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(spans))
+	for _, s := range spans {
+		edges = append(edges, edge{s.Start, 1}, edge{s.End, -1})
+	}
+	slices.SortFunc(edges, func(a, b edge) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.delta - b.delta
+	})
+
+	running, most := 0, 0
+	for _, e := range edges {
+		running += e.delta
+		most = max(most, running)
+	}
+	return most
+}
+
+// parallelScenario runs ParallelActivitiesWorkflow and checks all its
+// activities were running at the same time at some point. With a wide
+// fan-out, the reported max concurrency shows where the activity dispatcher
+// stops running activities in parallel.
+func parallelScenario(input ParallelInput) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(ParallelActivitiesWorkflow), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		if err := expectCompleted(metadata); err != nil {
+			return err
+		}
+		var spans []ActivitySpan
+		if err := json.Unmarshal([]byte(metadata.Output), &spans); err != nil {
+			return fmt.Errorf("failed to decode workflow output: %w", err)
+		}
+		if len(spans) != input.Count {
+			return fmt.Errorf("expected %d activity spans, got %d", input.Count, len(spans))
+		}
+
+		first, lastStart, lastEnd := spans[0].Start, spans[0].Start, spans[0].End
+		for _, s := range spans[1:] {
+			if s.Start.Before(first) {
+				first = s.Start
+			}
+			if s.Start.After(lastStart) {
+				lastStart = s.Start
+			}
+			if s.End.After(lastEnd) {
+				lastEnd = s.End
+			}
+		}
+		report := ParallelReport{
+			Activities:     len(spans),
+			MaxConcurrency: maxConcurrency(spans),
+			SpreadMs:       lastStart.Sub(first).Milliseconds(),
+			TotalMs:        lastEnd.Sub(first).Milliseconds(),
+		}
+		result.Details = report
+
+		if report.MaxConcurrency < input.Count {
+			return fmt.Errorf("at most %d of %d activities ran in parallel", report.MaxConcurrency, input.Count)
+		}
+		return nil
+	}
+}
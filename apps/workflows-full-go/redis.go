@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// redisAddr is the redis behind the workflow state store, see
+// tools/redis/Tiltfile.
+func redisAddr() string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "redis-master.default.svc.cluster.local:6379"
+}
+
+// countRedisKeys counts the keys matching pattern with SCAN. It speaks just
+// enough of the redis protocol for that, so the app doesn't need a redis
+// client only to watch the state store grow.
+func countRedisKeys(ctx context.Context, pattern string) (int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", redisAddr())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	r := bufio.NewReader(conn)
+	cursor, count := "0", 0
+	for {
+		cmd := []string{"SCAN", cursor, "MATCH", pattern, "COUNT", "1000"}
+		fmt.Fprintf(conn, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return 0, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return 0, fmt.Errorf("unexpected SCAN reply %v", reply)
+		}
+		keys, _ := parts[1].([]any)
+		count += len(keys)
+		cursor, _ = parts[0].(string)
+		if cursor == "0" {
+			return count, nil
+		}
+	}
+}
+
+// readRedisReply reads a RESP reply: strings for simple and bulk strings,
+// int64 for integers and []any for arrays.
+func readRedisReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("short redis reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, 0, n)
+		for range n {
+			item, err := readRedisReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+}
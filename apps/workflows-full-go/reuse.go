@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/api"
+)
+
+// reusePolicies are the ID reuse policies tried against each existing
+// instance, by name. They all apply to every status, so they'd kick in
+// whatever state the existing instance is in. The SDK marks reuse policies as
+// deprecated and the runtime doesn't apply them, so they're all expected to
+// behave like no policy at all.
+var reusePolicies = []struct {
+	Name   string
+	Action *api.CreateOrchestrationAction
+}{
+	{Name: "none"},
+	{Name: "error", Action: ptr(api.REUSE_ID_ACTION_ERROR)},
+	{Name: "ignore", Action: ptr(api.REUSE_ID_ACTION_IGNORE)},
+	{Name: "terminate", Action: ptr(api.REUSE_ID_ACTION_TERMINATE)},
+}
+
+var allStatuses = []wfclient.Status{
+	wfclient.StatusRunning,
+	wfclient.StatusCompleted,
+	wfclient.StatusFailed,
+	wfclient.StatusTerminated,
+	wfclient.StatusPending,
+	wfclient.StatusSuspended,
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// reusedInput is the input of the second schedule, so a restart can be told
+// apart from the existing instance.
+var reusedInput = WaitForEventsInput{EventName: "reused", Count: 1, Timeout: 0}
+
+type ReuseResult struct {
+	Policy string `json:"policy"`
+	// Outcome is "error", "restarted" if the existing instance was replaced,
+	// or "ignored" if the schedule succeeded but left it as it was.
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// reuseScenario creates an instance in the given status and schedules a new
+// one with the same instance ID under every reuse policy, expecting the given
+// outcome from all of them. Clients retry schedule calls, so this is what a
+// retried schedule does.
+func reuseScenario(existing wfclient.Status, want string) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		results := make([]ReuseResult, 0, len(reusePolicies))
+		var mismatches []string
+		for _, policy := range reusePolicies {
+			id := fmt.Sprintf("reuse-%s-%s-%d", strings.ToLower(string(existing)), policy.Name, time.Now().UnixNano())
+			result.setInstanceID(id)
+			if err := createInStatus(ctx, id, existing); err != nil {
+				return err
+			}
+
+			opts := []wfclient.ScheduleOption{wfclient.WithInstanceID(id), wfclient.WithInput(reusedInput)}
+			if policy.Action != nil {
+				opts = append(opts, wfclient.WithReuseIDPolicy(*policy.Action, allStatuses...))
+			}
+			res := ReuseResult{Policy: policy.Name}
+			if _, err := wfClient.ScheduleWorkflow(ctx, workflowName(WaitForEventsWorkflow), opts...); err != nil {
+				res.Outcome = "error"
+				res.Error = err.Error()
+			} else {
+				metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+				if err != nil {
+					return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+				}
+				res.Outcome = "ignored"
+				if strings.Contains(metadata.Input, reusedInput.EventName) {
+					res.Outcome = "restarted"
+				}
+			}
+			results = append(results, res)
+			if res.Outcome != want {
+				mismatches = append(mismatches, fmt.Sprintf("%s policy %s", policy.Name, res.Outcome))
+			}
+
+			if err := cleanUp(ctx, id); err != nil {
+				return err
+			}
+		}
+		result.Details = results
+
+		if len(mismatches) > 0 {
+			return fmt.Errorf("expected reusing the ID of a %s instance to be %s, got %s", existing, want, strings.Join(mismatches, ", "))
+		}
+		return nil
+	}
+}
+
+// createInStatus starts an instance with the given ID and waits until it's
+// in the given status.
+func createInStatus(ctx context.Context, id string, status wfclient.Status) error {
+	var err error
+	switch status {
+	case wfclient.StatusCompleted:
+		// Waiting with a zero timeout completes right away.
+		input := WaitForEventsInput{EventName: "never", Count: 1, Timeout: 0}
+		_, err = wfClient.ScheduleWorkflow(ctx, workflowName(WaitForEventsWorkflow), wfclient.WithInstanceID(id), wfclient.WithInput(input))
+	case wfclient.StatusFailed:
+		_, err = wfClient.ScheduleWorkflow(ctx, workflowName(FailingWorkflow), wfclient.WithInstanceID(id), wfclient.WithInput("failed on purpose"))
+	case wfclient.StatusRunning, wfclient.StatusTerminated:
+		input := WaitForEventsInput{EventName: "never", Count: 1, Timeout: -1}
+		_, err = wfClient.ScheduleWorkflow(ctx, workflowName(WaitForEventsWorkflow), wfclient.WithInstanceID(id), wfclient.WithInput(input))
+	default:
+		return fmt.Errorf("can't create an instance in status %s", status)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	if status == wfclient.StatusRunning || status == wfclient.StatusTerminated {
+		if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+			return err
+		}
+	}
+	if status == wfclient.StatusTerminated {
+		if err := wfClient.TerminateWorkflow(ctx, id); err != nil {
+			return fmt.Errorf("failed to terminate workflow: %w", err)
+		}
+	}
+	return waitForStatus(ctx, id, status)
+}
+
+// cleanUp terminates and purges an instance, so the next policy starts from
+// scratch.
+func cleanUp(ctx context.Context, id string) error {
+	// Terminating an instance that already finished is fine to fail, what
+	// matters is that it's finished before purging it.
+	_ = wfClient.TerminateWorkflow(ctx, id)
+	err := poll(ctx, fmt.Sprintf("%s never finished", id), func() (bool, error) {
+		metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+		if err != nil {
+			return false, fmt.Errorf("failed to fetch metadata of %s: %w", id, err)
+		}
+		return metadata.IsComplete(), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := wfClient.PurgeWorkflow(ctx, id); err != nil {
+		return fmt.Errorf("failed to purge workflow: %w", err)
+	}
+	return nil
+}
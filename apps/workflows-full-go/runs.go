@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/google/uuid"
+)
+
+// StartRequest picks a single scenario for /start to run in the background,
+// with params overriding its input if it's configurable.
+type StartRequest struct {
+	Scenario string          `json:"scenario,omitempty"`
+	Params   json.RawMessage `json:"params,omitempty"`
+}
+
+// maxRuns is how many runs /status/{id} keeps track of. Past it, the oldest
+// are forgotten.
+const maxRuns = 200
+
+// runs holds the scenarios started on their own, by the first instance they
+// started, and the suites of scenarios, by their run ID, so /status/{id} can
+// report how they went.
+var runs = &scenarioRuns{results: map[string]ScenarioResult{}, suites: map[string]*SuiteRun{}}
+
+// exclusive is held by the scenarios that change the state of the app for
+// everything else, see Scenario.Exclusive.
+var exclusive sync.Mutex
+
+type scenarioRuns struct {
+	mu      sync.Mutex
+	results map[string]ScenarioResult
+	suites  map[string]*SuiteRun
+	// order is the IDs of the runs, oldest first.
+	order []string
+}
+
+// track adds id to the runs, forgetting the oldest past maxRuns. Callers
+// hold r.mu.
+func (r *scenarioRuns) track(id string) {
+	r.order = append(r.order, id)
+	for len(r.order) > maxRuns {
+		delete(r.results, r.order[0])
+		delete(r.suites, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *scenarioRuns) set(id string, result ScenarioResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.results[id]; !ok {
+		r.track(id)
+	}
+	r.results[id] = result
+}
+
+func (r *scenarioRuns) get(id string) (ScenarioResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.results[id]
+	return result, ok
+}
+
+// latestSuite returns a copy of the last suite run named name.
+func (r *scenarioRuns) latestSuite(name string) (SuiteRun, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.order) - 1; i >= 0; i-- {
+		if suite, ok := r.suites[r.order[i]]; ok && suite.Name == name {
+			return suite.snapshot(), true
+		}
+	}
+	return SuiteRun{}, false
+}
+
+// suite returns a copy of the suite run with the given ID.
+func (r *scenarioRuns) suite(id string) (SuiteRun, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	suite, ok := r.suites[id]
+	if !ok {
+		return SuiteRun{}, false
+	}
+	return suite.snapshot(), true
+}
+
+// SuiteRun is a run of several scenarios in the background, one after the
+// other, reporting each result as soon as it's in.
+type SuiteRun struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Total is how many scenarios the suite runs, and Current the one
+	// running, with its result so far.
+	Total      int              `json:"total"`
+	Current    *ScenarioResult  `json:"current,omitempty"`
+	Scenarios  []ScenarioResult `json:"scenarios"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+func (s *SuiteRun) snapshot() SuiteRun {
+	snapshot := *s
+	snapshot.Scenarios = append([]ScenarioResult{}, s.Scenarios...)
+	if s.Current != nil {
+		current := *s.Current
+		snapshot.Current = &current
+	}
+	return snapshot
+}
+
+// startSuite runs scenarios in the background as a suite named name, and
+// returns its run ID. Exclusive scenarios wait for exclusive.
+func startSuite(name string, scenarios []Scenario) string {
+	suite := &SuiteRun{
+		ID:        name + "-" + uuid.NewString(),
+		Name:      name,
+		Status:    "running",
+		Total:     len(scenarios),
+		Scenarios: []ScenarioResult{},
+		StartedAt: time.Now(),
+	}
+	runs.mu.Lock()
+	runs.suites[suite.ID] = suite
+	runs.track(suite.ID)
+	runs.mu.Unlock()
+
+	go func() {
+		log.Printf("Running %d scenarios in %s", len(scenarios), suite.ID)
+		status := "passed"
+		for _, s := range scenarios {
+			if s.Exclusive {
+				exclusive.Lock()
+			}
+			runs.mu.Lock()
+			suite.Current = &ScenarioResult{Name: s.Name, Status: "running"}
+			runs.mu.Unlock()
+
+			result := runScenario(context.Background(), s, func(id string) {
+				runs.mu.Lock()
+				defer runs.mu.Unlock()
+				suite.Current.InstanceID = id
+			}, func(details any) {
+				runs.mu.Lock()
+				defer runs.mu.Unlock()
+				suite.Current.Details = details
+			})
+			if s.Exclusive {
+				exclusive.Unlock()
+			}
+			if result.Status != "passed" && result.Status != "skipped" {
+				status = "failed"
+			}
+
+			runs.mu.Lock()
+			suite.Current = nil
+			suite.Scenarios = append(suite.Scenarios, result)
+			runs.mu.Unlock()
+		}
+		log.Printf("Scenarios of %s %s", suite.ID, status)
+
+		runs.mu.Lock()
+		defer runs.mu.Unlock()
+		now := time.Now()
+		suite.Status = status
+		suite.FinishedAt = &now
+	}()
+	return suite.ID
+}
+
+// startScenario runs s in the background and returns as soon as it starts its
+// first instance, with the result so far. If s finishes without starting
+// one, it returns its final result instead. Exclusive scenarios hold
+// exclusive, which the caller already took.
+func startScenario(s Scenario) ScenarioResult {
+	started := make(chan ScenarioResult, 1)
+	done := make(chan ScenarioResult, 1)
+	go func() {
+		if s.Exclusive {
+			defer exclusive.Unlock()
+		}
+		var first string
+		result := runScenario(context.Background(), s, func(id string) {
+			first = id
+			running := ScenarioResult{Name: s.Name, Status: "running", InstanceID: id}
+			runs.set(id, running)
+			started <- running
+		}, nil)
+		if first != "" {
+			runs.set(first, result)
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-started:
+		return result
+	case result := <-done:
+		return result
+	}
+}
+
+// startScenarioHandler starts the scenario of the request, answering 202 with
+// the first instance it started, or 409 if it's exclusive and another
+// exclusive scenario is running.
+func startScenarioHandler(w http.ResponseWriter, req StartRequest) {
+	s, ok := findScenario(req.Scenario)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown scenario %q", req.Scenario), http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) > 0 {
+		if s.Configure == nil {
+			http.Error(w, fmt.Sprintf("Scenario %s takes no params", s.Name), http.StatusBadRequest)
+			return
+		}
+		run, err := s.Configure(req.Params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Run = run
+	}
+	if s.Exclusive && !exclusive.TryLock() {
+		http.Error(w, fmt.Sprintf("Scenario %s can't run alongside the scenario or suite running now", s.Name), http.StatusConflict)
+		return
+	}
+
+	log.Printf("Starting scenario %s", s.Name)
+	result := startScenario(s)
+	w.Header().Set("Content-Type", "application/json")
+	switch result.Status {
+	case "running":
+		w.WriteHeader(http.StatusAccepted)
+	case "passed", "skipped":
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// SuiteStartResponse is the answer to starting a suite of scenarios.
+type SuiteStartResponse struct {
+	ID string `json:"id"`
+	// StatusURL is where to follow the suite's progress.
+	StatusURL string `json:"status_url"`
+}
+
+// suiteStarted answers 202 with the run ID of the suite.
+func suiteStarted(w http.ResponseWriter, id string) {
+	appkit.WriteJSON(w, http.StatusAccepted, SuiteStartResponse{ID: id, StatusURL: "/status/" + id})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/api/helpers"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// scenarioTimeout bounds how long a single scenario can run, unless the
+// scenario sets its own timeout.
+const scenarioTimeout = 30 * time.Second
+
+// Scenario is a single conformance check. Scenarios run independently of each
+// other, so one broken feature doesn't hide the status of the others.
+type Scenario struct {
+	Name    string
+	Timeout time.Duration
+	Run     func(ctx context.Context, result *ScenarioResult) error
+	// Exclusive scenarios change the state of the app, or of the other
+	// apps, for everything else, so only one of them runs at a time.
+	Exclusive bool
+	// Configure, if set, returns a Run using the given params instead of the
+	// defaults, see configurable.
+	Configure func(params json.RawMessage) (func(ctx context.Context, result *ScenarioResult) error, error)
+}
+
+type ScenarioResult struct {
+	Name string `json:"name"`
+	// Status is "passed", "failed", "timeout", or "skipped" when the scenario
+	// couldn't check what it's about, see skipped.
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Timeline is where to look at the history of the instance, when the
+	// scenario didn't pass.
+	Timeline string `json:"timeline,omitempty"`
+	// Details holds scenario specific measurements.
+	Details any `json:"details,omitempty"`
+
+	onStart    func(id string)
+	onProgress func(details any)
+}
+
+// setInstanceID records the instance the scenario started. The first one is
+// reported to onStart, so /start can answer as soon as there's something to
+// check on.
+func (r *ScenarioResult) setInstanceID(id string) {
+	r.InstanceID = id
+	if r.onStart != nil {
+		r.onStart(id)
+		r.onStart = nil
+	}
+}
+
+// progress reports the details of the scenario so far to onProgress, for a
+// long running scenario to show how it's going. details mustn't change after.
+func (r *ScenarioResult) progress(details any) {
+	if r.onProgress != nil {
+		r.onProgress(details)
+	}
+}
+
+// skippedError is returned by scenarios that couldn't check what they're
+// about, such as when the sidecar can't return the history they check.
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string {
+	return e.reason
+}
+
+// skipped returns a skippedError, for the scenario to be reported as skipped
+// rather than passed or failed.
+func skipped(format string, args ...any) error {
+	return &skippedError{reason: fmt.Sprintf(format, args...)}
+}
+
+func isSkipped(err error) bool {
+	var skip *skippedError
+	return errors.As(err, &skip)
+}
+
+// configurable makes s run with base as input, or with params decoded on top
+// of it when started on its own. base goes through JSON first, so params
+// override only the fields they set, the way they would if sent in a workflow
+// input. Durations are in nanoseconds.
+func configurable[T any](s Scenario, base T, run func(T) func(ctx context.Context, result *ScenarioResult) error) Scenario {
+	s.Run = run(base)
+	s.Configure = func(params json.RawMessage) (func(ctx context.Context, result *ScenarioResult) error, error) {
+		encoded, err := json.Marshal(base)
+		if err != nil {
+			return nil, err
+		}
+		var input T
+		if err := json.Unmarshal(encoded, &input); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(params, &input); err != nil {
+			return nil, fmt.Errorf("invalid params for scenario %s: %w", s.Name, err)
+		}
+		return run(input), nil
+	}
+	return s
+}
+
+func findScenario(name string) (Scenario, bool) {
+	for _, s := range scenarios {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+var scenarios = []Scenario{
+	{Name: "activity", Run: workflowScenario(ActivityScenario)},
+	{Name: "same-app-child", Run: workflowScenario(SameAppChildScenario)},
+	{Name: "cross-app-child", Run: workflowScenario(CrossAppChildScenario)},
+	{Name: "continue-as-new-same-app", Run: workflowScenario(ContinueAsNewSameAppScenario)},
+	{Name: "continue-as-new-cross-app", Run: workflowScenario(ContinueAsNewCrossAppScenario)},
+	{Name: "event", Run: eventScenario(
+		WaitForEventsInput{EventName: "approval", Count: 1, Timeout: -1},
+		[]string{"approved"}, false,
+		WaitForEventsOutput{Events: []string{"approved"}},
+	)},
+	{Name: "event-within-timeout", Run: eventScenario(
+		WaitForEventsInput{EventName: "approval", Count: 1, Timeout: 10 * time.Second},
+		[]string{"approved"}, false,
+		WaitForEventsOutput{Events: []string{"approved"}},
+	)},
+	{Name: "event-timeout", Run: eventScenario(
+		WaitForEventsInput{EventName: "approval", Count: 1, Timeout: 2 * time.Second},
+		nil, false,
+		WaitForEventsOutput{TimedOut: true},
+	)},
+	{Name: "event-before-wait", Run: eventScenario(
+		WaitForEventsInput{EventName: "approval", Count: 1, Timeout: -1, Delay: 3 * time.Second},
+		[]string{"early"}, true,
+		WaitForEventsOutput{Events: []string{"early"}},
+	)},
+	{Name: "event-multiple", Run: eventScenario(
+		WaitForEventsInput{EventName: "item", Count: 3, Timeout: -1},
+		[]string{"1", "2", "3"}, false,
+		WaitForEventsOutput{Events: []string{"1", "2", "3"}},
+	)},
+	{Name: "event-cross-app", Run: crossAppEventScenario(
+		WaitForEventsInput{EventName: "approval", Count: 1, Timeout: -1},
+		[]string{"remote"},
+		WaitForEventsOutput{Events: []string{"remote"}},
+	)},
+	configurable(Scenario{Name: "timer-short"}, TimersInput{Delays: []time.Duration{1 * time.Second}}, timerScenario),
+	configurable(Scenario{Name: "timer-long", Timeout: 2 * time.Minute}, TimersInput{Delays: []time.Duration{60 * time.Second}}, timerScenario),
+	configurable(Scenario{Name: "timers-parallel"}, TimersInput{Delays: parallelDelays(50, 2*time.Second)}, timerScenario),
+	configurable(Scenario{Name: "timer-vs-activity"}, TimersInput{Delays: []time.Duration{500 * time.Millisecond, 3 * time.Second}, RaceActivity: true}, timerScenario),
+	configurable(Scenario{Name: "timer-vs-event"}, TimersInput{Delays: []time.Duration{2 * time.Second, 4 * time.Second}, RaceEvent: "ping"}, timerScenario),
+	{Name: "suspend-resume", Run: suspendResumeScenario},
+	{Name: "suspend-resume-cross-app-child", Run: suspendResumeCrossAppScenario},
+	{Name: "terminate-recursive", Run: terminateScenario},
+	{Name: "purge-recursive", Run: purgeScenario},
+	{Name: "failure-activity", Run: failureScenario("activity")},
+	{Name: "failure-same-app-child", Run: failureScenario("child")},
+	{Name: "failure-cross-app-child", Run: failureScenario("cross-app-child")},
+	configurable(Scenario{Name: "retry-then-succeed"}, RetryInput{Failures: 3, MaxAttempts: 5}, retryScenario),
+	configurable(Scenario{Name: "retry-exhausted"}, RetryInput{Failures: 10, MaxAttempts: 3}, retryScenario),
+	configurable(Scenario{Name: "parallel-activities"}, ParallelInput{Count: 2, Sleep: time.Second}, parallelScenario),
+	configurable(Scenario{Name: "parallel-activities-cross-app"}, ParallelInput{Count: 2, Sleep: time.Second, AppID: crossAppID}, parallelScenario),
+	configurable(Scenario{Name: "parallel-activities-100", Timeout: time.Minute}, ParallelInput{Count: 100, Sleep: 5 * time.Second}, parallelScenario),
+	{Name: "cross-app-routing", Run: routingScenario},
+	{Name: "versioning-changed-actions", Exclusive: true, Run: changedActionsScenario},
+	{Name: "versioning-changed-input", Exclusive: true, Run: changedInputScenario},
+	{Name: "versioning-pinned-by-activity", Exclusive: true, Run: pinnedVersionScenario},
+	{Name: "id-reuse-running", Run: reuseScenario(wfclient.StatusRunning, "error")},
+	{Name: "id-reuse-completed", Run: reuseScenario(wfclient.StatusCompleted, "restarted")},
+	{Name: "id-reuse-failed", Run: reuseScenario(wfclient.StatusFailed, "restarted")},
+	{Name: "id-reuse-terminated", Run: reuseScenario(wfclient.StatusTerminated, "restarted")},
+	{Name: "non-determinism-activity-to-timer", Run: nonDeterminismScenario(ndActivityToTimer, map[string]int{"TaskScheduled": 1})},
+	{Name: "non-determinism-activity-to-child", Run: nonDeterminismScenario(ndActivityToChild, map[string]int{"TaskScheduled": 1})},
+	{Name: "non-determinism-reordered", Run: nonDeterminismScenario(ndReordered, map[string]int{"TaskScheduled": 1, "SubOrchestrationInstanceCreated": 1})},
+	// Restarts workflows-full-go-2, so it runs after everything else using it.
+	{Name: "cross-app-failover", Timeout: 3 * time.Minute, Exclusive: true, Run: failoverScenario},
+}
+
+func workflowName(wf workflow.Workflow) string {
+	return helpers.GetTaskFunctionName(wf)
+}
+
+// workflowScenario runs a workflow that asserts on its own results, passing
+// when it completes successfully.
+func workflowScenario(wf workflow.Workflow) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(wf))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		return expectCompleted(metadata)
+	}
+}
+
+// expectCompleted fails unless the workflow completed successfully.
+func expectCompleted(metadata *wfclient.Metadata) error {
+	if metadata.Status == wfclient.StatusCompleted {
+		return nil
+	}
+	if metadata.FailureDetails != nil {
+		return fmt.Errorf("workflow failed with status: %s. Error: %s", metadata.Status, metadata.FailureDetails.ErrorMessage)
+	}
+	return fmt.Errorf("workflow failed with status: %s", metadata.Status)
+}
+
+// waitForCustomStatus polls the workflow until it reports the given custom
+// status.
+func waitForCustomStatus(ctx context.Context, id, status string) error {
+	for {
+		metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+		}
+		if metadata.CustomStatus == status {
+			return nil
+		}
+		if metadata.IsComplete() {
+			return fmt.Errorf("workflow finished with status %s before reporting %q", metadata.Status, status)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("workflow never reported %q: %w", status, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// runScenario runs s, calling onStart, if not nil, with the first instance it
+// starts.
+func runScenario(ctx context.Context, s Scenario, onStart func(id string), onProgress func(details any)) ScenarioResult {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = scenarioTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Running scenario %s", s.Name)
+	result := ScenarioResult{Name: s.Name, onStart: onStart, onProgress: onProgress}
+	start := time.Now()
+	err := s.Run(ctx, &result)
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	switch {
+	case err == nil:
+		result.Status = "passed"
+	case isSkipped(err):
+		result.Status = "skipped"
+		result.Error = err.Error()
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Status = "timeout"
+		result.Error = fmt.Sprintf("scenario timed out after %s: %v", timeout, err)
+	default:
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	if result.Status != "passed" && result.InstanceID != "" {
+		result.Timeline = "/timeline/" + result.InstanceID
+	}
+	log.Printf("Scenario %s %s in %dms", s.Name, result.Status, result.DurationMs)
+	result.onStart, result.onProgress = nil, nil
+	return result
+}
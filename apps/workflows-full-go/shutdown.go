@@ -0,0 +1,8 @@
+package main
+
+import "github.com/acroca/dapr-example-app/lib/go/appkit"
+
+// activities counts the activities the worker is running, for the shutdown
+// to wait on them before stopping it. Every activity starts by counting
+// itself in it.
+var activities appkit.InFlight
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// soakWindow is how many generations, or activities, each latency figure of
+// the soak workflows covers.
+const soakWindow = 100
+
+// soakSampleInterval is how often the soak scenarios sample the sidecar and
+// the state store.
+const soakSampleInterval = 5 * time.Second
+
+// EchoActivity returns its input, so the soak workflows spend their time in
+// the runtime rather than in activities.
+func EchoActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	var n int
+	ctx.GetInput(&n)
+	return n, nil
+}
+
+// LatencyWindows keeps the average and max latency of each window of
+// soakWindow steps, measured with the workflow clock so they're the same on
+// every replay.
+type LatencyWindows struct {
+	AvgMs []int64 `json:"avg_ms"`
+	MaxMs []int64 `json:"max_ms"`
+	// The window in progress.
+	Steps   int   `json:"steps"`
+	TotalMs int64 `json:"total_ms"`
+	PeakMs  int64 `json:"peak_ms"`
+}
+
+func (l *LatencyWindows) add(latency time.Duration) {
+	ms := latency.Milliseconds()
+	l.Steps++
+	l.TotalMs += ms
+	l.PeakMs = max(l.PeakMs, ms)
+	if l.Steps == soakWindow {
+		l.AvgMs = append(l.AvgMs, l.TotalMs/int64(l.Steps))
+		l.MaxMs = append(l.MaxMs, l.PeakMs)
+		l.Steps, l.TotalMs, l.PeakMs = 0, 0, 0
+	}
+}
+
+type EternalInput struct {
+	Generation  int `json:"generation"`
+	Generations int `json:"generations"`
+	// LastStart is when the previous generation started.
+	LastStart time.Time      `json:"last_start"`
+	Latency   LatencyWindows `json:"latency"`
+}
+
+// EternalWorkflow calls EchoActivity and continues as new, for the given
+// number of generations, the way long-lived monitor workflows do. It reports
+// the generation it's on as its custom status, and the time between the start
+// of each generation and the next as its output.
+func EternalWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input EternalInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	now := ctx.CurrentTimeUTC()
+	if input.Generation > 0 {
+		input.Latency.add(now.Sub(input.LastStart))
+	}
+	ctx.SetCustomStatus(strconv.Itoa(input.Generation))
+
+	if err := ctx.CallActivity(EchoActivity, workflow.WithActivityInput(input.Generation)).Await(nil); err != nil {
+		return nil, err
+	}
+	if input.Generation+1 >= input.Generations {
+		return input.Latency, nil
+	}
+	ctx.ContinueAsNew(EternalInput{
+		Generation:  input.Generation + 1,
+		Generations: input.Generations,
+		LastStart:   now,
+		Latency:     input.Latency,
+	})
+	return nil, nil
+}
+
+type LargeHistoryInput struct {
+	Activities int `json:"activities"`
+	// Batch is how many activities run at once.
+	Batch int `json:"batch"`
+}
+
+// LargeHistoryWorkflow calls EchoActivity the given number of times, Batch at
+// a time, so its history grows by thousands of events and every step replays
+// all of them. It reports the activities done as its custom status, and the
+// time each batch took, per activity, as its output.
+func LargeHistoryWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input LargeHistoryInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+	batch := max(input.Batch, 1)
+
+	var latency LatencyWindows
+	for done := 0; done < input.Activities; {
+		ctx.SetCustomStatus(strconv.Itoa(done))
+		start := ctx.CurrentTimeUTC()
+		n := min(batch, input.Activities-done)
+		tasks := make([]workflow.Task, 0, n)
+		for i := range n {
+			tasks = append(tasks, ctx.CallActivity(EchoActivity, workflow.WithActivityInput(done+i)))
+		}
+		for _, t := range tasks {
+			if err := t.Await(nil); err != nil {
+				return nil, err
+			}
+		}
+		elapsed := ctx.CurrentTimeUTC().Sub(start)
+		for range n {
+			latency.add(elapsed / time.Duration(n))
+		}
+		done += n
+	}
+	ctx.SetCustomStatus(strconv.Itoa(input.Activities))
+	return latency, nil
+}
+
+// SoakSample is a snapshot of a soak scenario while it runs.
+type SoakSample struct {
+	ElapsedMs int64 `json:"elapsed_ms"`
+	// Progress is the generation or activities done, from the custom status.
+	Progress int `json:"progress"`
+	// SidecarRSSBytes is the resident memory of the sidecar of this app.
+	SidecarRSSBytes int64 `json:"sidecar_rss_bytes,omitempty"`
+	// StateKeys is how many state store keys hold the instance's state.
+	StateKeys int    `json:"state_keys,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type SoakReport struct {
+	Samples []SoakSample   `json:"samples"`
+	Latency LatencyWindows `json:"latency"`
+}
+
+// sidecarMetricsURL is the Prometheus endpoint of the sidecar, which shares
+// the pod network with the app.
+func sidecarMetricsURL() string {
+	if url := os.Getenv("SIDECAR_METRICS_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:9090/metrics"
+}
+
+// sidecarRSS reads the resident memory of the sidecar from its metrics.
+func sidecarRSS(ctx context.Context) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sidecarMetricsURL(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "process_resident_memory_bytes ")
+		if !ok {
+			continue
+		}
+		rss, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid process_resident_memory_bytes %q: %w", value, err)
+		}
+		return int64(rss), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("sidecar metrics have no process_resident_memory_bytes")
+}
+
+// sample takes a SoakSample of the instance.
+func sample(ctx context.Context, id string, start time.Time) SoakSample {
+	s := SoakSample{ElapsedMs: time.Since(start).Milliseconds()}
+	var errs []string
+	if metadata, err := wfClient.FetchWorkflowMetadata(ctx, id); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		s.Progress, _ = strconv.Atoi(metadata.CustomStatus)
+	}
+	if rss, err := sidecarRSS(ctx); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		s.SidecarRSSBytes = rss
+	}
+	if keys, err := countRedisKeys(ctx, "*"+id+"*"); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		s.StateKeys = keys
+	}
+	s.Error = strings.Join(errs, "; ")
+	return s
+}
+
+// soakScenario runs a soak workflow, sampling it every soakSampleInterval
+// until it completes.
+func soakScenario(wf workflow.Workflow, input any) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(wf), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		// Only the sampling goroutine touches report until it's done, and
+		// it reports a copy of the samples so far on each tick.
+		report := &SoakReport{}
+		start := time.Now()
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(soakSampleInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					report.Samples = append(report.Samples, sample(ctx, id, start))
+					result.progress(SoakReport{Samples: slices.Clone(report.Samples)})
+				}
+			}
+		}()
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		close(done)
+		wg.Wait()
+		result.Details = report
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		report.Samples = append(report.Samples, sample(ctx, id, start))
+		if err := expectCompleted(metadata); err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(metadata.Output), &report.Latency); err != nil {
+			return fmt.Errorf("failed to decode workflow output: %w", err)
+		}
+		return nil
+	}
+}
+
+// soakSuite names the suites of soak scenarios. They take too long to run
+// with the other scenarios on /start.
+const soakSuite = "soak"
+
+// soakStart makes checking for a soak suite running and starting one atomic.
+var soakStart sync.Mutex
+
+// soakStartHandler starts the soak scenarios as a suite, one at a time. The
+// generations and activities query parameters size them.
+func soakStartHandler(w http.ResponseWriter, r *http.Request) {
+	generations, activities := 2000, 2000
+	for name, value := range map[string]*int{"generations": &generations, "activities": &activities} {
+		if q := r.URL.Query().Get(name); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n <= 0 {
+				http.Error(w, fmt.Sprintf("Invalid %s %q", name, q), http.StatusBadRequest)
+				return
+			}
+			*value = n
+		}
+	}
+
+	soakStart.Lock()
+	defer soakStart.Unlock()
+	if last, ok := runs.latestSuite(soakSuite); ok && last.FinishedAt == nil {
+		http.Error(w, fmt.Sprintf("Soak scenarios already running in %s", last.ID), http.StatusConflict)
+		return
+	}
+	suiteStarted(w, startSuite(soakSuite, []Scenario{
+		{Name: "soak-continue-as-new", Timeout: 2 * time.Hour, Run: soakScenario(EternalWorkflow, EternalInput{Generations: generations})},
+		{Name: "soak-large-history", Timeout: 2 * time.Hour, Run: soakScenario(LargeHistoryWorkflow, LargeHistoryInput{Activities: activities, Batch: 10})},
+	}))
+}
+
+// soakReportHandler reports the last soak suite, with the samples taken so
+// far of the scenario running, if it's still going.
+func soakReportHandler(w http.ResponseWriter, r *http.Request) {
+	suite, ok := runs.latestSuite(soakSuite)
+	if !ok {
+		http.Error(w, "Soak scenarios haven't run yet", http.StatusNotFound)
+		return
+	}
+	appkit.WriteJSON(w, http.StatusOK, suite)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+type StatusResponse struct {
+	InstanceID       string                   `json:"instance_id"`
+	Name             string                   `json:"name"`
+	RuntimeStatus    wfclient.Status          `json:"runtime_status"`
+	CustomStatus     string                   `json:"custom_status,omitempty"`
+	ParentInstanceID string                   `json:"parent_instance_id,omitempty"`
+	CreatedAt        *time.Time               `json:"created_at,omitempty"`
+	LastUpdatedAt    *time.Time               `json:"last_updated_at,omitempty"`
+	Input            string                   `json:"input,omitempty"`
+	Output           string                   `json:"output,omitempty"`
+	FailureDetails   *wfclient.FailureDetails `json:"failure_details,omitempty"`
+	// Backend is the workflow client backend that fetched the metadata.
+	Backend wfclient.Backend `json:"backend,omitempty"`
+	// Scenario is the result of the scenario started on its own with this
+	// instance, if any.
+	Scenario *ScenarioResult `json:"scenario,omitempty"`
+}
+
+// toFailureDetails converts the failure details of history events, which the
+// workflow client doesn't cover.
+func toFailureDetails(details *protos.TaskFailureDetails) *wfclient.FailureDetails {
+	if details == nil {
+		return nil
+	}
+	return &wfclient.FailureDetails{
+		ErrorType:      details.GetErrorType(),
+		ErrorMessage:   details.GetErrorMessage(),
+		StackTrace:     details.GetStackTrace().GetValue(),
+		InnerFailure:   toFailureDetails(details.GetInnerFailure()),
+		IsNonRetriable: details.GetIsNonRetriable(),
+	}
+}
+
+func toStatusResponse(metadata *wfclient.Metadata) StatusResponse {
+	return StatusResponse{
+		InstanceID:       metadata.InstanceID,
+		Name:             metadata.Name,
+		RuntimeStatus:    metadata.Status,
+		CustomStatus:     metadata.CustomStatus,
+		ParentInstanceID: metadata.ParentInstanceID,
+		CreatedAt:        &metadata.CreatedAt,
+		LastUpdatedAt:    &metadata.LastUpdatedAt,
+		Input:            metadata.Input,
+		Output:           metadata.Output,
+		FailureDetails:   metadata.FailureDetails,
+		Backend:          wfClient.Backend(),
+	}
+}
+
+// statusHandler reports the metadata of a workflow instance of this app, or
+// the progress of a suite of scenarios given its run ID. Other apps use it to
+// check on the instances they started here.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if suite, ok := runs.suite(id); ok {
+		appkit.WriteJSON(w, http.StatusOK, suite)
+		return
+	}
+	run, hasRun := runs.get(id)
+	metadata, err := wfClient.FetchWorkflowMetadata(r.Context(), id)
+	var response StatusResponse
+	switch {
+	case errors.Is(err, wfclient.ErrNotFound) && hasRun:
+		// The scenario picked the ID but hasn't created, or already purged,
+		// the instance.
+		response = StatusResponse{InstanceID: id}
+	case errors.Is(err, wfclient.ErrNotFound):
+		http.Error(w, fmt.Sprintf("Workflow %s not found", id), http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error fetching workflow metadata: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to fetch workflow metadata: %v", err), http.StatusInternalServerError)
+		return
+	default:
+		response = toStatusResponse(metadata)
+	}
+	if hasRun {
+		response.Scenario = &run
+	}
+
+	appkit.WriteJSON(w, http.StatusOK, response)
+}
+
+// invokeURL is the URL of a method of another app, through the Dapr HTTP API,
+// which keeps the status code the app answered with.
+func invokeURL(appID, method string) string {
+	daprPort := os.Getenv("DAPR_HTTP_PORT")
+	if daprPort == "" {
+		daprPort = "3500"
+	}
+	return fmt.Sprintf("http://localhost:%s/v1.0/invoke/%s/method/%s", daprPort, appID, method)
+}
+
+// remoteStatus fetches the status of a workflow instance running in another
+// app, through Dapr service invocation. It returns wfclient.ErrNotFound if
+// the instance doesn't exist there.
+func remoteStatus(ctx context.Context, appID, id string) (*StatusResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, invokeURL(appID, "status/"+id), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var status StatusResponse
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			return nil, err
+		}
+		return &status, nil
+	case http.StatusNotFound:
+		return nil, wfclient.ErrNotFound
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch status of %s from %s: %s: %s", id, appID, resp.Status, string(body))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+)
+
+// timelineHandler renders the history of a workflow instance of this app as
+// a timeline: as text, or as a page with format=html or when a browser asks
+// for HTML.
+func timelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	events, err := wfhistory.Fetch(r.Context(), daprClient.GrpcClientConn(), id)
+	if errors.Is(err, wfhistory.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching workflow history: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		http.Error(w, fmt.Sprintf("Workflow %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	if err := wfhistory.Render(w, r, id, events); err != nil {
+		log.Printf("Error rendering timeline of %s: %v", id, err)
+	}
+}
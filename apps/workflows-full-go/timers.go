@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// timerTolerance is how late a timer can fire before the scenario fails.
+const timerTolerance = 2 * time.Second
+
+type TimersInput struct {
+	Delays []time.Duration `json:"delays"`
+	// RaceActivity runs an activity while the timers are pending.
+	RaceActivity bool `json:"race_activity,omitempty"`
+	// RaceEvent waits for an event of this name while the timers are pending.
+	RaceEvent string `json:"race_event,omitempty"`
+}
+
+type TimerResult struct {
+	DelayMs    int64 `json:"delay_ms"`
+	LatenessMs int64 `json:"lateness_ms"`
+}
+
+type TimersOutput struct {
+	Timers []TimerResult `json:"timers"`
+}
+
+type TimersReport struct {
+	Timers        []TimerResult `json:"timers"`
+	MaxLatenessMs int64         `json:"max_lateness_ms"`
+	AvgLatenessMs int64         `json:"avg_lateness_ms"`
+}
+
+// TimersWorkflow creates all the timers at once and reports how late each of
+// them fired. It uses the workflow clock rather than the wall clock, so the
+// measurements are the same on every replay: the time after awaiting a timer
+// is the time the runtime processed its TimerFired event.
+func TimersWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input TimersInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+
+	// Await the timers from the shortest to the longest, so each measurement
+	// isn't held back by a longer timer awaited before it.
+	delays := slices.Clone(input.Delays)
+	slices.Sort(delays)
+
+	start := ctx.CurrentTimeUTC()
+	timers := make([]workflow.Task, 0, len(delays))
+	for _, delay := range delays {
+		timers = append(timers, ctx.CreateTimer(delay))
+	}
+	var activity, event workflow.Task
+	if input.RaceActivity {
+		activity = ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(1))
+	}
+	if input.RaceEvent != "" {
+		event = ctx.WaitForExternalEvent(input.RaceEvent, -1)
+	}
+	ctx.SetCustomStatus(waitingStatus)
+
+	var output TimersOutput
+	for i, timer := range timers {
+		if err := timer.Await(nil); err != nil {
+			return nil, err
+		}
+		lateness := ctx.CurrentTimeUTC().Sub(start.Add(delays[i]))
+		output.Timers = append(output.Timers, TimerResult{
+			DelayMs:    delays[i].Milliseconds(),
+			LatenessMs: lateness.Milliseconds(),
+		})
+	}
+
+	if activity != nil {
+		var number int
+		if err := activity.Await(&number); err != nil {
+			return nil, err
+		}
+		if err := expectNumber(number, 2); err != nil {
+			return nil, err
+		}
+	}
+	if event != nil {
+		if err := event.Await(nil); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// timerScenario runs TimersWorkflow, raising the race event if there's one,
+// and fails if any timer fired early or later than timerTolerance.
+func timerScenario(input TimersInput) func(ctx context.Context, result *ScenarioResult) error {
+	return func(ctx context.Context, result *ScenarioResult) error {
+		id, err := wfClient.ScheduleWorkflow(ctx, workflowName(TimersWorkflow), wfclient.WithInput(input))
+		if err != nil {
+			return fmt.Errorf("failed to start workflow: %w", err)
+		}
+		result.setInstanceID(id)
+
+		if input.RaceEvent != "" {
+			if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+				return err
+			}
+			if err := wfClient.RaiseEvent(ctx, id, input.RaceEvent); err != nil {
+				return fmt.Errorf("failed to raise event: %w", err)
+			}
+		}
+
+		metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to wait for workflow completion: %w", err)
+		}
+		if err := expectCompleted(metadata); err != nil {
+			return err
+		}
+		var output TimersOutput
+		if err := json.Unmarshal([]byte(metadata.Output), &output); err != nil {
+			return fmt.Errorf("failed to decode workflow output: %w", err)
+		}
+
+		report := TimersReport{Timers: output.Timers}
+		var total int64
+		for _, timer := range output.Timers {
+			report.MaxLatenessMs = max(report.MaxLatenessMs, timer.LatenessMs)
+			total += timer.LatenessMs
+		}
+		if len(output.Timers) > 0 {
+			report.AvgLatenessMs = total / int64(len(output.Timers))
+		}
+		result.Details = report
+
+		for _, timer := range output.Timers {
+			if timer.LatenessMs < 0 {
+				return fmt.Errorf("timer of %dms fired %dms early", timer.DelayMs, -timer.LatenessMs)
+			}
+			if timer.LatenessMs > timerTolerance.Milliseconds() {
+				return fmt.Errorf("timer of %dms fired %dms late, more than the %s tolerance", timer.DelayMs, timer.LatenessMs, timerTolerance)
+			}
+		}
+		return nil
+	}
+}
+
+// parallelDelays returns n copies of the same delay.
+func parallelDelays(n int, delay time.Duration) []time.Duration {
+	delays := make([]time.Duration, n)
+	for i := range delays {
+		delays[i] = delay
+	}
+	return delays
+}
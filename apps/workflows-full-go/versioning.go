@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// deployedVersion stands in for the version of VersionedWorkflow deployed in
+// this app. The worker replays a workflow from its history on every step, so
+// changing it between steps is how the definition changing looks to an
+// instance in flight. No pod restarts though, and no new worker replays the
+// instance: the scenarios check how the SDK replays a changed definition, not
+// a rollout. It's global like a real deployment, so the versioning scenarios
+// are exclusive.
+var deployedVersion atomic.Int32
+
+func init() {
+	deployedVersion.Store(1)
+}
+
+// The definitions of VersionedWorkflow:
+//   - 1 doubles N, waits for the "continue" event and doubles N again.
+//   - 2 waits on a short timer before the first activity, which changes the
+//     actions the workflow takes before the event.
+//   - 3 passes N+1 to the first activity instead, which doesn't change the
+//     actions, only their inputs.
+const (
+	versionInitial      = 1
+	versionAddedTimer   = 2
+	versionChangedInput = 3
+)
+
+type VersionedInput struct {
+	N int `json:"n"`
+	// Pinned makes the workflow record the version it started on in its
+	// history, through VersionActivity, and keep following that version
+	// after a redeploy. durabletask-go v0.10.1 has no patching API, this is
+	// the pattern workflows have without one.
+	Pinned bool `json:"pinned"`
+}
+
+type VersionedOutput struct {
+	Version int `json:"version"`
+	N       int `json:"n"`
+}
+
+// VersionActivity returns the deployed version. As an activity, its result is
+// recorded in the history, so the workflow gets the same answer on replays.
+func VersionActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return deployedVersion.Load(), nil
+}
+
+// VersionedWorkflow runs the deployed definition, or the one it started on
+// when pinned.
+func VersionedWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input VersionedInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, err
+	}
+
+	// Reading the deployed version straight away is the point when not
+	// pinned: it's what changing the code does to a replay.
+	version := int(deployedVersion.Load())
+	if input.Pinned {
+		if err := ctx.CallActivity(VersionActivity).Await(&version); err != nil {
+			return nil, err
+		}
+	}
+
+	n := input.N
+	if version == versionAddedTimer {
+		if err := ctx.CreateTimer(100 * time.Millisecond).Await(nil); err != nil {
+			return nil, err
+		}
+	}
+	first := n
+	if version == versionChangedInput {
+		first = n + 1
+	}
+	if err := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(first)).Await(&n); err != nil {
+		return nil, err
+	}
+
+	ctx.SetCustomStatus(waitingStatus)
+	if err := ctx.WaitForExternalEvent("continue", -1).Await(nil); err != nil {
+		return nil, err
+	}
+	if err := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(n)).Await(&n); err != nil {
+		return nil, err
+	}
+	return VersionedOutput{Version: version, N: n}, nil
+}
+
+// nonDeterminismErrorPrefix starts the errors the SDK raises when a replay
+// takes a different action than the history recorded.
+const nonDeterminismErrorPrefix = "a previous execution called"
+
+func isNonDeterminismError(msg string) bool {
+	return strings.Contains(msg, nonDeterminismErrorPrefix)
+}
+
+// versioningNote is reported by every versioning scenario, so their results
+// aren't read as more than they cover.
+const versioningNote = "Not a real redeploy: the app swaps the workflow definition in process, " +
+	"without restarting its pod or starting a new worker, so versioning across a rollout isn't covered. " +
+	"durabletask-go v0.10.1 has no patching API, so patching isn't covered either: pinning runs the version recorded by an activity instead."
+
+type VersioningReport struct {
+	// Redeploy is how the new version was deployed, always "simulated".
+	Redeploy    string `json:"redeploy"`
+	Note        string `json:"note"`
+	StartedOn   int    `json:"started_on"`
+	ContinuedOn int    `json:"continued_on"`
+	// Outcome is "replayed" if the instance carried on after the redeploy,
+	// or "non-determinism error" if the replay was rejected.
+	Outcome string           `json:"outcome"`
+	Error   string           `json:"error,omitempty"`
+	Output  *VersionedOutput `json:"output,omitempty"`
+}
+
+// redeploy runs VersionedWorkflow on version from, swaps deployedVersion to
+// version to while it waits for its event, and lets it continue. It reports
+// how the replay on the new version went.
+func redeploy(ctx context.Context, result *ScenarioResult, input VersionedInput, from, to int) (*VersioningReport, error) {
+	deployedVersion.Store(int32(from))
+	defer deployedVersion.Store(versionInitial)
+
+	id, err := wfClient.ScheduleWorkflow(ctx, workflowName(VersionedWorkflow), wfclient.WithInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	}
+	result.setInstanceID(id)
+	if err := waitForCustomStatus(ctx, id, waitingStatus); err != nil {
+		return nil, err
+	}
+
+	deployedVersion.Store(int32(to))
+	if err := wfClient.RaiseEvent(ctx, id, "continue"); err != nil {
+		return nil, fmt.Errorf("failed to raise event: %w", err)
+	}
+	metadata, err := wfClient.WaitForWorkflowCompletion(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for workflow completion: %w", err)
+	}
+
+	report := &VersioningReport{Redeploy: "simulated", Note: versioningNote, StartedOn: from, ContinuedOn: to}
+	result.Details = report
+	switch {
+	case metadata.Status == wfclient.StatusCompleted:
+		report.Outcome = "replayed"
+		report.Output = &VersionedOutput{}
+		if err := json.Unmarshal([]byte(metadata.Output), report.Output); err != nil {
+			return nil, fmt.Errorf("failed to decode workflow output: %w", err)
+		}
+	case metadata.FailureDetails != nil && isNonDeterminismError(metadata.FailureDetails.ErrorMessage):
+		report.Outcome = "non-determinism error"
+		report.Error = metadata.FailureDetails.ErrorMessage
+	default:
+		return nil, expectCompleted(metadata)
+	}
+	return report, nil
+}
+
+// changedActionsScenario changes the actions of an instance in flight, which
+// the replay has to reject.
+func changedActionsScenario(ctx context.Context, result *ScenarioResult) error {
+	report, err := redeploy(ctx, result, VersionedInput{N: 4}, versionInitial, versionAddedTimer)
+	if err != nil {
+		return err
+	}
+	if report.Outcome != "non-determinism error" {
+		return fmt.Errorf("expected the replay to raise a non-determinism error, it %s with %+v", report.Outcome, report.Output)
+	}
+	return nil
+}
+
+// changedInputScenario changes only the input of an activity that already
+// ran. The SDK doesn't compare inputs, so the replay carries on with the
+// result recorded for the old input: this is the change it can't catch.
+func changedInputScenario(ctx context.Context, result *ScenarioResult) error {
+	report, err := redeploy(ctx, result, VersionedInput{N: 4}, versionInitial, versionChangedInput)
+	if err != nil {
+		return err
+	}
+	want := VersionedOutput{Version: versionChangedInput, N: 16}
+	if report.Outcome != "replayed" || *report.Output != want {
+		return fmt.Errorf("expected the replay to carry on undetected with %+v, got %s %+v", want, report.Outcome, report.Output)
+	}
+	return nil
+}
+
+// pinnedVersionScenario makes the same change as changedActionsScenario
+// behind the version recorded in the history. The instance in flight has to
+// finish on the version it started on, and a new instance has to run the new
+// one.
+func pinnedVersionScenario(ctx context.Context, result *ScenarioResult) error {
+	input := VersionedInput{N: 4, Pinned: true}
+	report, err := redeploy(ctx, result, input, versionInitial, versionAddedTimer)
+	if err != nil {
+		return err
+	}
+	want := VersionedOutput{Version: versionInitial, N: 16}
+	if report.Outcome != "replayed" || *report.Output != want {
+		return fmt.Errorf("expected the instance in flight to finish with %+v, got %s %+v", want, report.Outcome, report.Output)
+	}
+
+	// A new instance on the redeployed app takes the new path.
+	var fresh ScenarioResult
+	report, err = redeploy(ctx, &fresh, input, versionAddedTimer, versionAddedTimer)
+	if err != nil {
+		return err
+	}
+	want = VersionedOutput{Version: versionAddedTimer, N: 16}
+	if report.Outcome != "replayed" || *report.Output != want {
+		return fmt.Errorf("expected a new instance to finish with %+v, got %s %+v", want, report.Outcome, report.Output)
+	}
+	result.Details = map[string]any{"in_flight": result.Details, "new": report}
+	return nil
+}
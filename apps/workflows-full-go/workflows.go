@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// crossAppID is the app the cross-app scenarios run their children in.
+const crossAppID = "workflows-full-go-2"
+
+// routingAppID is a second app to run children in, to check each child runs
+// in the app it was sent to.
+const routingAppID = "workflows-full-go-3"
+
+func expectNumber(got, want int) error {
+	if got != want {
+		return fmt.Errorf("number is not %d, is %d", want, got)
+	}
+	return nil
+}
+
+// ActivityScenario tests a simple activity call from a root workflow.
+func ActivityScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var number int
+	err := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(4)).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectNumber(number, 8); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+// SameAppChildScenario tests a child workflow call from a root workflow, in
+// the same app.
+func SameAppChildScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var number int
+	err := ctx.CallChildWorkflow(ChildWorkflowAsyncActivities, workflow.WithChildWorkflowInput(4)).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectNumber(number, 16); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+// CrossAppChildScenario tests a child workflow call from a root workflow, in
+// a different app.
+func CrossAppChildScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var number int
+	err := ctx.CallChildWorkflow(ChildWorkflowAsyncActivities, workflow.WithChildWorkflowInput(5), workflow.WithChildWorkflowAppID(crossAppID)).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectNumber(number, 20); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+// ContinueAsNewSameAppScenario tests a child workflow with ContinueAsNew, in
+// the same app.
+func ContinueAsNewSameAppScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var number int
+	err := ctx.CallChildWorkflow(ChildWorkflowNTimes, workflow.WithChildWorkflowInput(&ChildWorkflow2xNTimesInput{N: 4, Times: 3})).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectNumber(number, 32); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+// ContinueAsNewCrossAppScenario tests a child workflow with ContinueAsNew, in
+// a different app.
+func ContinueAsNewCrossAppScenario(ctx *workflow.WorkflowContext) (any, error) {
+	var number int
+	err := ctx.CallChildWorkflow(ChildWorkflowNTimes, workflow.WithChildWorkflowInput(&ChildWorkflow2xNTimesInput{N: 5, Times: 3}), workflow.WithChildWorkflowAppID(crossAppID)).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectNumber(number, 40); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+// ChildWorkflowAsyncActivities calls DoubleActivity twice asynchronously, returning 4x the input.
+// Whether the activities really run in parallel is checked by the parallel
+// scenarios, from the activities' own timestamps.
+func ChildWorkflowAsyncActivities(ctx *workflow.WorkflowContext) (any, error) {
+	var n int
+	ctx.GetInput(&n)
+
+	a1 := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(n))
+	a2 := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(n))
+
+	var n1, n2 int
+	err := a1.Await(&n1)
+	if err != nil {
+		return nil, err
+	}
+	err = a2.Await(&n2)
+	if err != nil {
+		return nil, err
+	}
+	return n1 + n2, nil
+}
+
+type ChildWorkflow2xNTimesInput struct {
+	N     int `json:"n"`
+	Times int `json:"times"`
+}
+
+// ChildWorkflowNTimes calls DoubleActivity N times, returning 2^N * input. It's done using ContinueAsNew until Times is 1.
+func ChildWorkflowNTimes(ctx *workflow.WorkflowContext) (any, error) {
+	var input ChildWorkflow2xNTimesInput
+	ctx.GetInput(&input)
+	var number int
+	err := ctx.CallActivity(DoubleActivity, workflow.WithActivityInput(input.N)).Await(&number)
+	if err != nil {
+		return nil, err
+	}
+	if input.Times > 1 {
+		ctx.ContinueAsNew(&ChildWorkflow2xNTimesInput{
+			N:     number,
+			Times: input.Times - 1,
+		})
+	}
+	return number, nil
+}
+
+func DoubleActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	time.Sleep(1 * time.Second)
+	var n int
+	ctx.GetInput(&n)
+	return n * 2, nil
+}
@@ -1,54 +1,43 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
 	dapr "github.com/dapr/go-sdk/client"
 	"github.com/dapr/go-sdk/workflow"
 )
 
-var wfClient *workflow.Client
+// wfClient goes through the SDK set in WORKFLOW_CLIENT_BACKEND, go-sdk by
+// default. The worker always uses go-sdk.
+var wfClient wfclient.Client
+var daprClient dapr.Client
 
 type WorkflowRequest struct {
 	Input string `json:"input,omitempty"`
+	// InstanceID is optional, the runtime generates one if empty.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Scenario picks the workflow to run, see scenarios.
+	Scenario string `json:"scenario,omitempty"`
 }
 
-type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-}
-
-type WorkflowResponse struct {
-	Status     string `json:"status"`
-	InstanceID string `json:"instance_id"`
-	Result     string `json:"result,omitempty"`
-	Message    string `json:"message,omitempty"`
-	Error      string `json:"error,omitempty"`
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	response := HealthResponse{
-		Status:    "healthy",
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// scenarios maps the scenario names /start accepts to the workflow they run.
+var scenarios = map[string]string{
+	"test": "TestWorkflow",
+	"wait": "WaitWorkflow",
+	"fail": "FailWorkflow",
+	"slow": "SlowWorkflow",
 }
 
+// startWorkflowHandler starts the workflow of the requested scenario, "test"
+// by default, and answers 202 with its instance ID without waiting for it.
+// GET /status/{id} reports how it went.
 func startWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -63,100 +52,42 @@ func startWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.Scenario == "" {
+		req.Scenario = "test"
+	}
+	name, ok := scenarios[req.Scenario]
+	if !ok {
+		appkit.WriteError(w, http.StatusBadRequest, "", "Unknown scenario %q", req.Scenario)
+		return
+	}
+
 	// Use current timestamp as default input if none provided
 	workflowInput := req.Input
 	if workflowInput == "" {
 		workflowInput = time.Now().Format(time.RFC3339)
 	}
 
-	log.Printf("Starting workflow with input: %s", workflowInput)
+	log.Printf("Starting workflow %s with input: %s", name, workflowInput)
 
 	// Start workflow
-	id, err := wfClient.ScheduleNewWorkflow(context.Background(), "TestWorkflow", workflow.WithInput(workflowInput))
+	opts := []wfclient.ScheduleOption{wfclient.WithInput(workflowInput)}
+	if req.InstanceID != "" {
+		opts = append(opts, wfclient.WithInstanceID(req.InstanceID))
+	}
+	id, err := wfClient.ScheduleWorkflow(r.Context(), name, opts...)
 	if err != nil {
 		log.Printf("Error starting workflow: %v", err)
-		response := WorkflowResponse{
-			Status: "failed",
-			Error:  fmt.Sprintf("Failed to start workflow: %v", err),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
+		appkit.WriteError(w, http.StatusInternalServerError, req.InstanceID, "Failed to start workflow: %v", err)
 		return
 	}
 
 	log.Printf("Workflow started with instance ID: %s", id)
-
-	// Wait for workflow completion with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	_, err = wfClient.WaitForWorkflowCompletion(ctx, id)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Workflow timed out!")
-			response := WorkflowResponse{
-				Status:     "timeout",
-				InstanceID: id,
-				Message:    "Workflow execution timed out",
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusRequestTimeout)
-			json.NewEncoder(w).Encode(response)
-			return
-		}
-		log.Printf("Error waiting for workflow completion: %v", err)
-		response := WorkflowResponse{
-			Status:     "failed",
-			InstanceID: id,
-			Error:      fmt.Sprintf("Failed to wait for workflow completion: %v", err),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	// Fetch workflow result
-	respFetch, err := wfClient.FetchWorkflowMetadata(context.Background(), id, workflow.WithFetchPayloads(true))
-	if err != nil {
-		log.Printf("Error fetching workflow metadata: %v", err)
-		response := WorkflowResponse{
-			Status:     "failed",
-			InstanceID: id,
-			Error:      fmt.Sprintf("Failed to get workflow result: %v", err),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	if respFetch.RuntimeStatus.String() != "COMPLETED" {
-		log.Printf("Workflow failed! Status: %s", respFetch.RuntimeStatus.String())
-		response := WorkflowResponse{
-			Status:     "failed",
-			InstanceID: id,
-			Error:      fmt.Sprintf("Workflow failed with status: %s", respFetch.RuntimeStatus.String()),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	log.Printf("Workflow completed! Result: %s", string(respFetch.SerializedOutput))
-	response := WorkflowResponse{
-		Status:     "completed",
-		InstanceID: id,
-		Result:     string(respFetch.SerializedOutput),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	appkit.WriteJSON(w, http.StatusAccepted, appkit.WorkflowResponse{Status: "started", InstanceID: id})
 }
 
 func main() {
+	app := appkit.New("workflows-go", "6006")
+
 	// Create and start workflow worker
 	w, err := workflow.NewWorker()
 	if err != nil {
@@ -166,40 +97,66 @@ func main() {
 	if err := w.RegisterWorkflow(TestWorkflow); err != nil {
 		log.Fatal(err)
 	}
+	if err := w.RegisterWorkflow(WaitWorkflow); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.RegisterWorkflow(FailWorkflow); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.RegisterWorkflow(SlowWorkflow); err != nil {
+		log.Fatal(err)
+	}
 	if err := w.RegisterActivity(TestActivity); err != nil {
 		log.Fatal(err)
 	}
+	if err := w.RegisterActivity(SlowActivity); err != nil {
+		log.Fatal(err)
+	}
 
 	if err := w.Start(); err != nil {
 		log.Fatal(err)
 	}
+	app.DrainWorker(&activities, func() { w.Shutdown() })
 
 	// Create Dapr client
-	client, err := dapr.NewClient()
+	daprClient, err = app.DaprClient()
 	if err != nil {
-		panic(err)
+		log.Fatalf("failed to create dapr client: %v", err)
 	}
-	defer client.Close()
 
 	// Create workflow client
-	wfClient, err = workflow.NewClient(workflow.WithDaprClient(client))
+	backend, err := wfclient.BackendFromEnv(wfclient.BackendGoSDK)
+	if err != nil {
+		log.Fatal(err)
+	}
+	wfClient, err = wfclient.New(backend, daprClient)
 	if err != nil {
 		log.Fatalf("failed to initialise workflow client: %v", err)
 	}
+	log.Printf("Using the %s workflow client", backend)
 
 	// Setup HTTP routes
-	http.HandleFunc("/healthz", healthHandler)
-	http.HandleFunc("/start", startWorkflowHandler)
-
-	// Get port from environment variable or use default
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		appPort = "6006"
+	// Starting workflows stops once the app is shutting down. Those already
+	// running can still be checked on and managed while it drains.
+	app.HandleFunc("/start", app.StopOnShutdown(startWorkflowHandler))
+	app.HandleFunc("/status/{id}", statusHandler)
+	app.HandleFunc("POST /workflows", app.StopOnShutdown(startWorkflowHandler))
+	app.HandleFunc("GET /workflows/{id}", statusHandler)
+	app.HandleFunc("DELETE /workflows/{id}", purgeHandler)
+	app.HandleFunc("GET /workflows/{id}/history", historyHandler)
+	app.HandleFunc("GET /workflows/{id}/timeline", timelineHandler)
+	app.HandleFunc("GET /workflows/{id}/events", eventsHandler)
+	app.HandleFunc("POST /workflows/{id}/events/{name}", raiseEventHandler)
+	app.HandleFunc("POST /workflows/{id}/suspend", suspendHandler)
+	app.HandleFunc("POST /workflows/{id}/resume", resumeHandler)
+	app.HandleFunc("POST /workflows/{id}/terminate", terminateHandler)
+	app.HandleFunc("/scenarios/id-reuse", app.StopOnShutdown(reuseHandler))
+
+	app.CheckSidecar(appkit.SidecarRequirements{Workflows: true})
+
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
 	}
-
-	// Start HTTP server
-	log.Printf("Starting HTTP server on port %s", appPort)
-	log.Fatal(http.ListenAndServe(":"+appPort, nil))
 }
 
 func TestWorkflow(ctx *workflow.WorkflowContext) (any, error) {
@@ -212,5 +169,10 @@ func TestWorkflow(ctx *workflow.WorkflowContext) (any, error) {
 }
 
 func TestActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	return rand.Intn(100000), nil
 }
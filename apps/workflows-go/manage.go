@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ReasonRequest is the optional body of the suspend and resume endpoints.
+type ReasonRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// HistoryResponse holds the history events of an instance, in the protobuf
+// JSON encoding.
+type HistoryResponse struct {
+	InstanceID string            `json:"instance_id"`
+	Events     []json.RawMessage `json:"events"`
+}
+
+// writeClientError answers with the error of a workflow client call,
+// as a 404 if the instance doesn't exist.
+func writeClientError(w http.ResponseWriter, id, action string, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, wfclient.ErrNotFound) {
+		code = http.StatusNotFound
+	} else {
+		log.Printf("Error trying to %s workflow %s: %v", action, id, err)
+	}
+	appkit.WriteError(w, code, id, "Failed to %s workflow: %v", action, err)
+}
+
+func writeDone(w http.ResponseWriter, id, status string) {
+	appkit.WriteJSON(w, http.StatusOK, appkit.WorkflowResponse{Status: status, InstanceID: id})
+}
+
+// recursive reads the recursive query parameter, true unless set otherwise.
+func recursive(r *http.Request) (bool, error) {
+	q := r.URL.Query().Get("recursive")
+	if q == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(q)
+}
+
+// readReason reads the optional ReasonRequest body.
+func readReason(r *http.Request) (string, error) {
+	var req ReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return req.Reason, nil
+}
+
+// raiseEventHandler raises the named event on an instance, with the request
+// body, if any, as its JSON payload.
+func raiseEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, name := r.PathValue("id"), r.PathValue("name")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Failed to read request body: %v", err)
+		return
+	}
+	var opts []wfclient.RaiseEventOption
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			appkit.WriteError(w, http.StatusBadRequest, id, "Event payload isn't valid JSON")
+			return
+		}
+		opts = append(opts, wfclient.WithRawEventData(string(body)))
+	}
+	if err := wfClient.RaiseEvent(r.Context(), id, name, opts...); err != nil {
+		writeClientError(w, id, "raise event on", err)
+		return
+	}
+	writeDone(w, id, "event raised")
+}
+
+func suspendHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reason, err := readReason(r)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Invalid request body: %v", err)
+		return
+	}
+	if err := wfClient.SuspendWorkflow(r.Context(), id, reason); err != nil {
+		writeClientError(w, id, "suspend", err)
+		return
+	}
+	writeDone(w, id, "suspended")
+}
+
+func resumeHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reason, err := readReason(r)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Invalid request body: %v", err)
+		return
+	}
+	if err := wfClient.ResumeWorkflow(r.Context(), id, reason); err != nil {
+		writeClientError(w, id, "resume", err)
+		return
+	}
+	writeDone(w, id, "resumed")
+}
+
+// terminateHandler terminates an instance and, unless recursive=false, its
+// children. The request body, if any, is the output to terminate it with.
+func terminateHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	rec, err := recursive(r)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Invalid recursive: %v", err)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Failed to read request body: %v", err)
+		return
+	}
+	opts := []wfclient.TerminateOption{wfclient.WithRecursiveTerminate(rec)}
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			appkit.WriteError(w, http.StatusBadRequest, id, "Output isn't valid JSON")
+			return
+		}
+		opts = append(opts, wfclient.WithRawOutput(string(body)))
+	}
+	if err := wfClient.TerminateWorkflow(r.Context(), id, opts...); err != nil {
+		writeClientError(w, id, "terminate", err)
+		return
+	}
+	writeDone(w, id, "terminated")
+}
+
+// purgeHandler deletes the state of a finished instance and, unless
+// recursive=false, of its children.
+func purgeHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	rec, err := recursive(r)
+	if err != nil {
+		appkit.WriteError(w, http.StatusBadRequest, id, "Invalid recursive: %v", err)
+		return
+	}
+	if err := wfClient.PurgeWorkflow(r.Context(), id, wfclient.WithRecursivePurge(rec)); err != nil {
+		writeClientError(w, id, "purge", err)
+		return
+	}
+	writeDone(w, id, "purged")
+}
+
+// historyHandler returns the history events of an instance, or 501 if the
+// sidecar can't return history.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	events, err := wfhistory.Fetch(r.Context(), daprClient.GrpcClientConn(), id)
+	if errors.Is(err, wfhistory.ErrUnsupported) {
+		appkit.WriteError(w, http.StatusNotImplemented, id, "%v", err)
+		return
+	}
+	if err != nil {
+		writeClientError(w, id, "fetch history of", err)
+		return
+	}
+
+	response := HistoryResponse{InstanceID: id, Events: make([]json.RawMessage, 0, len(events))}
+	for _, e := range events {
+		encoded, err := protojson.Marshal(e)
+		if err != nil {
+			appkit.WriteError(w, http.StatusInternalServerError, id, "Failed to encode history event: %v", err)
+			return
+		}
+		response.Events = append(response.Events, encoded)
+	}
+	appkit.WriteJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+	"github.com/dapr/go-sdk/workflow"
+)
+
+// waitingStatus is the custom status WaitWorkflow sets once it's waiting.
+const waitingStatus = "waiting"
+
+// WaitWorkflow waits for the "finish" event forever.
+func WaitWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	ctx.SetCustomStatus(waitingStatus)
+	if err := ctx.WaitForExternalEvent("finish", -1).Await(nil); err != nil {
+		return nil, err
+	}
+	return "finished", nil
+}
+
+// FailWorkflow always fails.
+func FailWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	return nil, errors.New("failed on purpose")
+}
+
+// reusePolicies are the ID reuse policies tried against each existing
+// instance, by name. They all apply to every status. Reuse policies are
+// deprecated and the runtime doesn't apply them, so they're all expected to
+// behave like no policy at all.
+var reusePolicies = []struct {
+	Name   string
+	Action *workflow.CreateWorkflowAction
+}{
+	{Name: "none"},
+	{Name: "error", Action: ptr(workflow.ReuseIDActionError)},
+	{Name: "ignore", Action: ptr(workflow.ReuseIDActionIgnore)},
+	{Name: "terminate", Action: ptr(workflow.ReuseIDActionTerminate)},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// reuseCases are the states of the existing instance, and what scheduling a
+// new one with the same ID is expected to do: "error", "restarted" if the
+// existing instance gets replaced, or "ignored" if the schedule succeeds but
+// leaves it as it was.
+var reuseCases = []struct {
+	Existing wfclient.Status
+	Want     string
+}{
+	{Existing: wfclient.StatusRunning, Want: "error"},
+	{Existing: wfclient.StatusCompleted, Want: "restarted"},
+	{Existing: wfclient.StatusFailed, Want: "restarted"},
+	{Existing: wfclient.StatusTerminated, Want: "restarted"},
+}
+
+// reusedInput is the input of the second schedule, so a restart can be told
+// apart from the existing instance.
+const reusedInput = "reused"
+
+type ReuseResult struct {
+	Existing string `json:"existing"`
+	Policy   string `json:"policy"`
+	Want     string `json:"want"`
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ReuseResponse struct {
+	Status  string        `json:"status"`
+	Results []ReuseResult `json:"results"`
+}
+
+// reuseHandler schedules workflows with an instance ID that's already taken,
+// for every state of the existing instance and every reuse policy. Clients
+// retry schedule calls, so this is what a retried schedule does.
+func reuseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	response := ReuseResponse{Status: "passed"}
+	for _, c := range reuseCases {
+		for _, policy := range reusePolicies {
+			res, err := reuseID(ctx, c.Existing, policy.Name, policy.Action)
+			if err != nil {
+				log.Printf("ID reuse check of a %s instance with %s policy failed: %v", c.Existing, policy.Name, err)
+				res.Outcome = "failed"
+				res.Error = err.Error()
+			}
+			res.Want = c.Want
+			if res.Outcome != c.Want {
+				response.Status = "failed"
+			}
+			response.Results = append(response.Results, res)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != "passed" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func reuseID(ctx context.Context, existing wfclient.Status, policyName string, action *workflow.CreateWorkflowAction) (ReuseResult, error) {
+	res := ReuseResult{Existing: string(existing), Policy: policyName}
+	id := fmt.Sprintf("reuse-%s-%s-%d", strings.ToLower(string(existing)), policyName, time.Now().UnixNano())
+	if err := createInStatus(ctx, id, existing); err != nil {
+		return res, err
+	}
+	defer cleanUp(ctx, id)
+
+	opts := []wfclient.ScheduleOption{wfclient.WithInstanceID(id), wfclient.WithInput(reusedInput)}
+	if action != nil {
+		opts = append(opts, wfclient.WithReuseIDPolicy(*action,
+			wfclient.StatusRunning,
+			wfclient.StatusCompleted,
+			wfclient.StatusFailed,
+			wfclient.StatusTerminated,
+			wfclient.StatusPending,
+			wfclient.StatusSuspended,
+		))
+	}
+	if _, err := wfClient.ScheduleWorkflow(ctx, "TestWorkflow", opts...); err != nil {
+		res.Outcome = "error"
+		res.Error = err.Error()
+		return res, nil
+	}
+
+	metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+	if err != nil {
+		return res, fmt.Errorf("failed to fetch workflow metadata: %w", err)
+	}
+	res.Outcome = "ignored"
+	if strings.Contains(metadata.Input, reusedInput) {
+		res.Outcome = "restarted"
+	}
+	return res, nil
+}
+
+// createInStatus starts an instance with the given ID and waits until it's
+// in the given status.
+func createInStatus(ctx context.Context, id string, status wfclient.Status) error {
+	name := "WaitWorkflow"
+	switch status {
+	case wfclient.StatusCompleted:
+		name = "TestWorkflow"
+	case wfclient.StatusFailed:
+		name = "FailWorkflow"
+	}
+	if _, err := wfClient.ScheduleWorkflow(ctx, name, wfclient.WithInstanceID(id)); err != nil {
+		return fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	for {
+		metadata, err := wfClient.FetchWorkflowMetadata(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to fetch workflow metadata: %w", err)
+		}
+		if status == wfclient.StatusTerminated && metadata.CustomStatus != "" && metadata.Status == wfclient.StatusRunning {
+			if err := wfClient.TerminateWorkflow(ctx, id); err != nil {
+				return fmt.Errorf("failed to terminate workflow: %w", err)
+			}
+		}
+		if metadata.Status == status && (status != wfclient.StatusRunning || metadata.CustomStatus != "") {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("workflow %s never reached %s, last %s: %w", id, status, metadata.Status, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// cleanUp terminates and purges an instance. Terminating fails for instances
+// that already finished, which is fine.
+func cleanUp(ctx context.Context, id string) {
+	_ = wfClient.TerminateWorkflow(ctx, id)
+	if _, err := wfClient.WaitForWorkflowCompletion(ctx, id); err != nil {
+		log.Printf("Error waiting for workflow %s to finish: %v", id, err)
+		return
+	}
+	if err := wfClient.PurgeWorkflow(ctx, id); err != nil {
+		log.Printf("Error purging workflow %s: %v", id, err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/dapr/go-sdk/workflow"
+)
+
+// activities counts the activities running in this app. The worker only
+// stops once they're done, or DRAIN_TIMEOUT passed.
+var activities appkit.InFlight
+
+// slowActivityDuration is how long SlowActivity runs, long enough to stop the
+// app while it's running.
+const slowActivityDuration = 20 * time.Second
+
+// SlowWorkflow runs SlowActivity, to see what happens to an activity running
+// while the app shuts down, gracefully or not.
+func SlowWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var slept string
+	if err := ctx.CallActivity(SlowActivity).Await(&slept); err != nil {
+		return nil, err
+	}
+	return "Slept for " + slept, nil
+}
+
+// SlowActivity sleeps for slowActivityDuration. It doesn't stop on shutdown,
+// a graceful one waits for it.
+func SlowActivity(ctx workflow.ActivityContext) (any, error) {
+	done, err := activities.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	time.Sleep(slowActivityDuration)
+	return slowActivityDuration.String(), nil
+}
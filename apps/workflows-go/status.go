@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
+)
+
+// StatusResponse is the metadata of an instance, along with the client
+// backend that fetched it.
+type StatusResponse struct {
+	*wfclient.Metadata
+	Backend wfclient.Backend `json:"backend"`
+}
+
+func toStatusResponse(metadata *wfclient.Metadata) StatusResponse {
+	return StatusResponse{Metadata: metadata, Backend: wfClient.Backend()}
+}
+
+// statusHandler reports the metadata, output and failure details of a
+// workflow instance.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	metadata, err := wfClient.FetchWorkflowMetadata(r.Context(), id)
+	if err != nil {
+		writeClientError(w, id, "fetch metadata of", err)
+		return
+	}
+
+	appkit.WriteJSON(w, http.StatusOK, toStatusResponse(metadata))
+}
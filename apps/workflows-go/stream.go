@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// streamPollInterval is how often eventsHandler polls the instance. The
+// sidecar has no way to watch an instance, so the stream is built by polling.
+const streamPollInterval = 500 * time.Millisecond
+
+// sseWriter writes server-sent events, flushing each one.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s sseWriter) send(event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// eventsHandler streams the progress of an instance as server-sent events
+// until it completes:
+//   - "status", with a StatusResponse, whenever its runtime or custom status
+//     changes.
+//   - "history", with a history event in the protobuf JSON encoding, for every
+//     event appended to its history. If the sidecar can't return history, a
+//     single "history-unsupported" event is sent instead.
+//   - "error", with a WorkflowResponse, if polling fails. The stream ends
+//     after it.
+//
+// The last "status" event is the one with the completed instance.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		appkit.WriteError(w, http.StatusInternalServerError, id, "Streaming isn't supported")
+		return
+	}
+
+	// Fail with a regular response if the instance doesn't exist.
+	metadata, err := wfClient.FetchWorkflowMetadata(r.Context(), id)
+	if err != nil {
+		writeClientError(w, id, "fetch metadata of", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	sse := sseWriter{w: w, flusher: flusher}
+
+	ctx := r.Context()
+	var last *StatusResponse
+	sentEvents, historySupported := 0, true
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	for {
+		// Check the history before the status, so the events that completed
+		// the instance go out before the status saying so.
+		if historySupported {
+			events, err := wfhistory.Fetch(ctx, daprClient.GrpcClientConn(), id)
+			switch {
+			case errors.Is(err, wfhistory.ErrUnsupported):
+				historySupported = false
+				sse.send("history-unsupported", appkit.WorkflowResponse{Status: "unsupported", InstanceID: id, Message: err.Error()})
+			case err != nil:
+				sse.send("error", appkit.WorkflowResponse{Status: "failed", InstanceID: id, Error: err.Error()})
+				return
+			default:
+				if len(events) < sentEvents {
+					// It continued as new, and the history started over.
+					sentEvents = 0
+				}
+				for _, e := range events[sentEvents:] {
+					encoded, err := protojson.Marshal(e)
+					if err != nil {
+						sse.send("error", appkit.WorkflowResponse{Status: "failed", InstanceID: id, Error: err.Error()})
+						return
+					}
+					if err := sse.send("history", json.RawMessage(encoded)); err != nil {
+						return
+					}
+				}
+				sentEvents = len(events)
+			}
+		}
+
+		if metadata == nil {
+			metadata, err = wfClient.FetchWorkflowMetadata(ctx, id)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error streaming workflow %s: %v", id, err)
+					sse.send("error", appkit.WorkflowResponse{Status: "failed", InstanceID: id, Error: err.Error()})
+				}
+				return
+			}
+		}
+		status := toStatusResponse(metadata)
+		if last == nil || status.Status != last.Status || status.CustomStatus != last.CustomStatus {
+			if err := sse.send("status", status); err != nil {
+				return
+			}
+			last = &status
+		}
+		if metadata.IsComplete() {
+			return
+		}
+		metadata = nil
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfhistory"
+)
+
+// timelineHandler renders the history of an instance as a timeline: as text,
+// or as a page with format=html or when a browser asks for HTML.
+func timelineHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	events, err := wfhistory.Fetch(r.Context(), daprClient.GrpcClientConn(), id)
+	if errors.Is(err, wfhistory.ErrUnsupported) {
+		appkit.WriteError(w, http.StatusNotImplemented, id, "%v", err)
+		return
+	}
+	if err != nil {
+		writeClientError(w, id, "fetch history of", err)
+		return
+	}
+	if len(events) == 0 {
+		appkit.WriteError(w, http.StatusNotFound, id, "Workflow %s not found", id)
+		return
+	}
+
+	if err := wfhistory.Render(w, r, id, events); err != nil {
+		log.Printf("Error rendering timeline of %s: %v", id, err)
+	}
+}
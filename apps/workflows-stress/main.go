@@ -4,13 +4,13 @@ import (
 	"context"
 	"log"
 	"math/rand"
-	"os/signal"
 	"strconv"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
+	"github.com/acroca/dapr-example-app/lib/go/wfclient"
 	dapr "github.com/dapr/go-sdk/client"
 	"github.com/dapr/go-sdk/workflow"
 	"github.com/google/uuid"
@@ -20,6 +20,10 @@ var count atomic.Uint64
 var waitingForWorflows atomic.Int64
 
 func main() {
+	// The stress app doesn't serve HTTP, the app only runs until it's told
+	// to stop.
+	app := appkit.New("workflows-stress", "")
+
 	// Create and start workflow worker
 	w, err := workflow.NewWorker()
 	if err != nil {
@@ -37,10 +41,16 @@ func main() {
 		log.Fatal(err)
 	}
 
-	log.Printf("Starting continuous workflow execution...")
+	// The workflows are scheduled through the SDK set in
+	// WORKFLOW_CLIENT_BACKEND, go-sdk by default.
+	backend, err := wfclient.BackendFromEnv(wfclient.BackendGoSDK)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	log.Printf("Starting continuous workflow execution with the %s workflow client...", backend)
+
+	ctx := app.Context()
 
 	concurrentWorkflowRuns := 3
 	workers := 3
@@ -52,7 +62,7 @@ func main() {
 	for range workers {
 		go func() {
 			defer wg.Done()
-			createWorkflowWorker(ctx, sem)
+			createWorkflowWorker(ctx, sem, backend)
 		}()
 	}
 
@@ -74,17 +84,34 @@ func main() {
 		}
 	}()
 
-	wg.Wait()
+	// Let the runs in flight finish before exiting.
+	app.OnShutdown(func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func createWorkflowWorker(ctx context.Context, sem chan struct{}) error {
+func createWorkflowWorker(ctx context.Context, sem chan struct{}, backend wfclient.Backend) error {
 	client, err := dapr.NewClient()
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	wfClient, err := workflow.NewClient(workflow.WithDaprClient(client))
+	wfClient, err := wfclient.New(backend, client)
 	if err != nil {
 		return err
 	}
@@ -102,7 +129,7 @@ func createWorkflowWorker(ctx context.Context, sem chan struct{}) error {
 	}
 }
 
-func RunWorkflow(wfClient *workflow.Client) error {
+func RunWorkflow(wfClient wfclient.Client) error {
 	// Use current timestamp as workflow input
 	workflowInput := time.Now().Format(time.RFC3339)
 
@@ -111,7 +138,7 @@ func RunWorkflow(wfClient *workflow.Client) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	// Start workflow
-	_, err := wfClient.ScheduleNewWorkflow(ctx, "TestWorkflow", workflow.WithInput(workflowInput), workflow.WithInstanceID(workflowID))
+	_, err := wfClient.ScheduleWorkflow(ctx, "TestWorkflow", wfclient.WithInput(workflowInput), wfclient.WithInstanceID(workflowID))
 	if err != nil {
 		log.Printf("Error scheduling workflow (id: %s): %v\n", workflowID, err)
 		return err
@@ -126,12 +153,12 @@ func RunWorkflow(wfClient *workflow.Client) error {
 	}
 
 	// // Fetch workflow result
-	// respFetch, err := wfClient.FetchWorkflowMetadata(context.Background(), id, workflow.WithFetchPayloads(true))
+	// respFetch, err := wfClient.FetchWorkflowMetadata(context.Background(), id)
 	// if err != nil {
 	// 	return err
 	// }
-	// if respFetch.RuntimeStatus.String() != "COMPLETED" {
-	// 	return fmt.Errorf("workflow %s failed! Status: %s", id, respFetch.RuntimeStatus.String())
+	// if respFetch.Status != wfclient.StatusCompleted {
+	// 	return fmt.Errorf("workflow %s failed! Status: %s", id, respFetch.Status)
 	// }
 	count.Add(1)
 	return nil
@@ -0,0 +1,224 @@
+// Package appkit is what the Go apps have in common: an HTTP router with
+// health and readiness endpoints, JSON responses, structured logging, a Dapr
+// client and a graceful shutdown on SIGTERM.
+//
+// An app creates an App, registers its routes and shutdown hooks, and calls
+// Run:
+//
+//	app := appkit.New("my-app", "6000")
+//	app.HandleFunc("POST /start", startHandler)
+//	if err := app.Run(); err != nil {
+//		log.Fatal(err)
+//	}
+package appkit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+const (
+	// DefaultDrainTimeout is how long Run waits for the drain hooks when
+	// DRAIN_TIMEOUT isn't set.
+	DefaultDrainTimeout = 30 * time.Second
+	// DefaultShutdownTimeout is how long Run waits for in-flight requests and
+	// shutdown hooks when SHUTDOWN_TIMEOUT isn't set.
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+type App struct {
+	Name string
+	// Port is APP_PORT, or the default port given to New. Apps with neither
+	// don't serve HTTP.
+	Port   string
+	Logger *slog.Logger
+	// DrainTimeout bounds the drain hooks, DRAIN_TIMEOUT or
+	// DefaultDrainTimeout.
+	DrainTimeout time.Duration
+	// ShutdownTimeout bounds the rest of the shutdown, once drained,
+	// SHUTDOWN_TIMEOUT or DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	mux          *http.ServeMux
+	ctx          context.Context
+	stop         context.CancelFunc
+	shuttingDown atomic.Bool
+
+	mu         sync.Mutex
+	checks     []readinessCheck
+	sidecar    *SidecarRequirements
+	drainHooks []func(context.Context) error
+	hooks      []func(context.Context) error
+	dapr       dapr.Client
+}
+
+// New sets up the app and the default logger. defaultPort is used if
+// APP_PORT isn't set, and may be empty for apps that don't serve HTTP.
+func New(name, defaultPort string) *App {
+	logger := newLogger(name)
+	slog.SetDefault(logger)
+
+	a := &App{
+		Name:            name,
+		Port:            envOrDefault("APP_PORT", defaultPort),
+		Logger:          logger,
+		DrainTimeout:    durationEnv(logger, "DRAIN_TIMEOUT", DefaultDrainTimeout),
+		ShutdownTimeout: durationEnv(logger, "SHUTDOWN_TIMEOUT", DefaultShutdownTimeout),
+		mux:             http.NewServeMux(),
+	}
+	a.ctx, a.stop = signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	a.mux.HandleFunc("GET /healthz", a.healthHandler)
+	a.mux.HandleFunc("GET /readyz", a.readyHandler)
+	return a
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func durationEnv(logger *slog.Logger, key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("Ignoring invalid "+key, "value", v, "error", err)
+		return def
+	}
+	return d
+}
+
+// Context is canceled once the app is asked to shut down, for background
+// loops to stop on.
+func (a *App) Context() context.Context {
+	return a.ctx
+}
+
+// ShuttingDown reports whether the app was asked to shut down.
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
+
+// Handle registers a route, with the patterns of http.ServeMux.
+func (a *App) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a route, with the patterns of http.ServeMux.
+func (a *App) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	a.mux.HandleFunc(pattern, handler)
+}
+
+// OnShutdown registers a hook to run once the app drained and the HTTP server
+// stopped. Hooks run in reverse order of registration, before the Dapr client
+// is closed.
+func (a *App) OnShutdown(hook func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks = append(a.hooks, hook)
+}
+
+// Run serves HTTP until SIGINT or SIGTERM, then shuts down: it reports not
+// ready and runs the drain hooks within DrainTimeout, then lets in-flight
+// requests finish, runs the shutdown hooks and closes the Dapr client within
+// ShutdownTimeout.
+func (a *App) Run() error {
+	defer a.stop()
+
+	var srv *http.Server
+	serveErr := make(chan error, 1)
+	if a.Port != "" {
+		srv = &http.Server{Addr: ":" + a.Port, Handler: a.mux}
+		go func() {
+			a.Logger.Info("Starting HTTP server", "port", a.Port)
+			serveErr <- srv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		a.shutdown(nil)
+		return err
+	case <-a.ctx.Done():
+	}
+	a.Logger.Info("Shutting down", "drain_timeout", a.DrainTimeout.String(), "timeout", a.ShutdownTimeout.String())
+	a.shuttingDown.Store(true)
+	drainErr := a.drain()
+	return errors.Join(drainErr, a.shutdown(srv))
+}
+
+// drain runs the drain hooks. The shutdown goes on if they fail, or don't
+// finish in time.
+func (a *App) drain() error {
+	a.mu.Lock()
+	hooks := a.drainHooks
+	a.mu.Unlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.DrainTimeout)
+	defer cancel()
+	start := time.Now()
+	var errs []error
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		a.Logger.Error("Drain failed", "error", err, "duration", time.Since(start).Round(time.Millisecond).String())
+	} else {
+		a.Logger.Info("Drained", "duration", time.Since(start).Round(time.Millisecond).String())
+	}
+	return err
+}
+
+func (a *App) shutdown(srv *http.Server) error {
+	a.shuttingDown.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	a.mu.Lock()
+	hooks, client := a.hooks, a.dapr
+	a.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if client != nil {
+		client.Close()
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		a.Logger.Error("Shutdown failed", "error", err)
+	} else {
+		a.Logger.Info("Shut down")
+	}
+	return err
+}
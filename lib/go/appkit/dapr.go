@@ -0,0 +1,22 @@
+package appkit
+
+import (
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+// DaprClient returns the Dapr client of the app, connecting on first use. Run
+// closes it on shutdown, after the shutdown hooks, so apps mustn't close it
+// themselves.
+func (a *App) DaprClient() (dapr.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.dapr != nil {
+		return a.dapr, nil
+	}
+	client, err := dapr.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	a.dapr = client
+	return client, nil
+}
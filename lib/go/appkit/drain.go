@@ -0,0 +1,155 @@
+package appkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrDraining is returned by InFlight.Start once the drain started.
+var ErrDraining = errors.New("draining, not taking new work")
+
+// InFlight counts work in progress, such as the activities a workflow worker
+// runs, for a drain hook to wait on. The zero value is ready to use.
+type InFlight struct {
+	mu       sync.Mutex
+	n        int
+	idle     chan struct{}
+	draining bool
+	// stopped is closed once the worker stopped, see DrainWorker.
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// Start counts a piece of work until the returned func is called. Activities
+// call it before anything else:
+//
+//	done, err := activities.Start()
+//	if err != nil {
+//		return nil, err
+//	}
+//	defer done()
+//
+// Once the drain started, it takes no new work: it blocks until the worker
+// stopped and returns ErrDraining. The worker can't report the result by
+// then, so the sidecar hands the work out again, to another replica or once
+// the app is back.
+func (f *InFlight) Start() (done func(), err error) {
+	f.mu.Lock()
+	if f.draining {
+		stopped := f.stoppedLocked()
+		f.mu.Unlock()
+		<-stopped
+		return nil, ErrDraining
+	}
+	f.n++
+	f.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(f.done)
+	}, nil
+}
+
+func (f *InFlight) stoppedLocked() chan struct{} {
+	if f.stopped == nil {
+		f.stopped = make(chan struct{})
+	}
+	return f.stopped
+}
+
+// drain makes Start hold new work until stop.
+func (f *InFlight) drain() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.draining = true
+}
+
+// stop releases the work Start held, once the worker stopped.
+func (f *InFlight) stop() {
+	f.mu.Lock()
+	stopped := f.stoppedLocked()
+	f.mu.Unlock()
+	f.stopOnce.Do(func() { close(stopped) })
+}
+
+func (f *InFlight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n--
+	if f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// Len returns how much work is in progress.
+func (f *InFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// Wait blocks until no work is in progress, or returns the error of ctx if
+// it's done first.
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnDrain registers a hook to run as soon as the app is asked to shut down,
+// while the HTTP server still serves, for the app to finish its work in
+// progress. Hooks run in order of registration, all within DrainTimeout.
+func (a *App) OnDrain(hook func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drainHooks = append(a.drainHooks, hook)
+}
+
+// StopOnShutdown wraps a handler that starts new work, so it answers 503 once
+// the app is shutting down instead.
+func (a *App) StopOnShutdown(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.ShuttingDown() {
+			WriteError(w, http.StatusServiceUnavailable, "", "%s is shutting down", a.Name)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// DrainWorker registers a drain hook for a workflow worker. It first stops
+// the worker from taking new work: from then on, inFlight.Start holds what the
+// worker receives instead of running it. Then it waits for the work already
+// counted in inFlight, and calls stop. stop is called on timeout too.
+//
+// The worker keeps its connection open until stop, since the SDKs report the
+// results of activities through it.
+func (a *App) DrainWorker(inFlight *InFlight, stop func()) {
+	a.OnDrain(func(ctx context.Context) error {
+		inFlight.drain()
+		a.Logger.Info("Draining the workflow worker", "in_flight", inFlight.Len())
+		err := inFlight.Wait(ctx)
+		if err != nil {
+			a.Logger.Warn("Stopping the workflow worker with work in flight", "in_flight", inFlight.Len(), "error", err)
+		}
+		stop()
+		inFlight.stop()
+		return err
+	})
+}
@@ -0,0 +1,90 @@
+package appkit
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds each readiness check.
+const readinessTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ReadinessResponse reports the result of every readiness check, "ok" or
+// the error, by name, and what the sidecar reports if the app checks it.
+type ReadinessResponse struct {
+	Status    string            `json:"status"`
+	Timestamp string            `json:"timestamp"`
+	Checks    map[string]string `json:"checks,omitempty"`
+	Sidecar   *SidecarStatus    `json:"sidecar,omitempty"`
+}
+
+type readinessCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// AddReadinessCheck adds a check GET /readyz runs. The app is ready once all
+// of them pass.
+func (a *App) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.checks = append(a.checks, readinessCheck{name: name, check: check})
+}
+
+// healthHandler answers as long as the process serves requests.
+func (a *App) healthHandler(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// readyHandler answers 503 while shutting down or while a readiness check
+// fails.
+func (a *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if a.ShuttingDown() {
+		response.Status = "shutting down"
+		WriteJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	a.mu.Lock()
+	checks, sidecar := a.checks, a.sidecar
+	a.mu.Unlock()
+	if sidecar != nil {
+		checks = append([]readinessCheck{{name: "sidecar", check: func(ctx context.Context) error {
+			var err error
+			response.Sidecar, err = checkSidecar(ctx, *sidecar)
+			return err
+		}}}, checks...)
+	}
+	if len(checks) > 0 {
+		response.Checks = make(map[string]string, len(checks))
+	}
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := c.check(ctx)
+		cancel()
+		if err != nil {
+			response.Status = "not ready"
+			response.Checks[c.name] = err.Error()
+			continue
+		}
+		response.Checks[c.name] = "ok"
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	WriteJSON(w, status, response)
+}
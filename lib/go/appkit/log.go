@@ -0,0 +1,23 @@
+package appkit
+
+import (
+	"log/slog"
+	"os"
+)
+
+// newLogger logs JSON to stdout, tagged with the app name. LOG_FORMAT=text
+// switches to plain text and LOG_LEVEL, e.g. "debug", sets the level.
+func newLogger(name string) *slog.Logger {
+	opts := &slog.HandlerOptions{}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err == nil {
+			opts.Level = level
+		}
+	}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
+	if os.Getenv("LOG_FORMAT") == "text" {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(handler).With("app", name)
+}
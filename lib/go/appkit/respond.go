@@ -0,0 +1,36 @@
+package appkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// WorkflowResponse is how the apps answer requests about a workflow instance.
+type WorkflowResponse struct {
+	Status     string `json:"status"`
+	InstanceID string `json:"instance_id"`
+	Result     string `json:"result,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WriteJSON answers with v encoded as JSON.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error writing response", "error", err)
+	}
+}
+
+// WriteError answers with a failed WorkflowResponse, so every endpoint fails
+// the same way. id is the instance the request was about, if any.
+func WriteError(w http.ResponseWriter, status int, id string, format string, args ...any) {
+	WriteJSON(w, status, WorkflowResponse{
+		Status:     "failed",
+		InstanceID: id,
+		Error:      fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,199 @@
+package appkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SidecarRequirements is what the app needs from its sidecar, on top of it
+// being up, to be ready.
+type SidecarRequirements struct {
+	// Components are the names of the components the app uses.
+	Components []string
+	// Workflows requires a workflow worker connected to the sidecar.
+	Workflows bool
+	// Actors requires the actor runtime running, with the app registered as
+	// an actor host.
+	Actors bool
+}
+
+// SidecarStatus is what the sidecar reports in its metadata.
+type SidecarStatus struct {
+	AppID          string                `json:"app_id"`
+	RuntimeVersion string                `json:"runtime_version,omitempty"`
+	Components     []SidecarComponent    `json:"components"`
+	Subscriptions  []SidecarSubscription `json:"subscriptions"`
+	Workflows      SidecarWorkflows      `json:"workflows"`
+	Actors         SidecarActors         `json:"actors"`
+}
+
+type SidecarComponent struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+}
+
+type SidecarSubscription struct {
+	PubsubName string `json:"pubsub_name"`
+	Topic      string `json:"topic"`
+}
+
+type SidecarWorkflows struct {
+	ConnectedWorkers int `json:"connected_workers"`
+}
+
+type SidecarActors struct {
+	// RuntimeStatus is INITIALIZING, DISABLED or RUNNING.
+	RuntimeStatus string `json:"runtime_status"`
+	HostReady     bool   `json:"host_ready"`
+	Placement     string `json:"placement,omitempty"`
+	// ActiveActors counts the active actors by type.
+	ActiveActors map[string]int `json:"active_actors,omitempty"`
+}
+
+// sidecarMetadata is the part of the sidecar's GET /v1.0/metadata response
+// SidecarStatus covers.
+type sidecarMetadata struct {
+	ID             string `json:"id"`
+	RuntimeVersion string `json:"runtimeVersion"`
+	Components     []struct {
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		Version string `json:"version"`
+	} `json:"components"`
+	Subscriptions []struct {
+		PubsubName string `json:"pubsubname"`
+		Topic      string `json:"topic"`
+	} `json:"subscriptions"`
+	ActorRuntime struct {
+		RuntimeStatus string `json:"runtimeStatus"`
+		HostReady     bool   `json:"hostReady"`
+		Placement     string `json:"placement"`
+		ActiveActors  []struct {
+			Type  string `json:"type"`
+			Count int    `json:"count"`
+		} `json:"activeActors"`
+	} `json:"actorRuntime"`
+	Workflows struct {
+		ConnectedWorkers int `json:"connectedWorkers"`
+	} `json:"workflows"`
+}
+
+// CheckSidecar makes GET /readyz check the sidecar too: its outbound health,
+// which fails until its components are initialized, and its metadata against
+// req. The response reports the metadata either way.
+func (a *App) CheckSidecar(req SidecarRequirements) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sidecar = &req
+}
+
+// sidecarURL is the address of the sidecar's HTTP API.
+func sidecarURL(path string) string {
+	return fmt.Sprintf("http://localhost:%s%s", envOrDefault("DAPR_HTTP_PORT", "3500"), path)
+}
+
+// checkSidecar returns the status of the sidecar, and an error if it isn't
+// healthy or doesn't meet req. The status is nil if the metadata couldn't be
+// fetched.
+func checkSidecar(ctx context.Context, req SidecarRequirements) (*SidecarStatus, error) {
+	if err := sidecarOutboundHealth(ctx); err != nil {
+		return nil, err
+	}
+	status, err := fetchSidecarStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, name := range req.Components {
+		if !slices.ContainsFunc(status.Components, func(c SidecarComponent) bool { return c.Name == name }) {
+			problems = append(problems, fmt.Sprintf("component %s isn't loaded", name))
+		}
+	}
+	if req.Workflows && status.Workflows.ConnectedWorkers == 0 {
+		problems = append(problems, "no workflow worker is connected")
+	}
+	if req.Actors {
+		if status.Actors.RuntimeStatus != "RUNNING" {
+			problems = append(problems, fmt.Sprintf("actor runtime is %s", status.Actors.RuntimeStatus))
+		} else if !status.Actors.HostReady {
+			problems = append(problems, "actor host isn't ready")
+		}
+	}
+	if len(problems) > 0 {
+		return status, errors.New(strings.Join(problems, "; "))
+	}
+	return status, nil
+}
+
+// sidecarOutboundHealth checks the sidecar can serve the app's calls, without
+// depending on the app being up.
+func sidecarOutboundHealth(ctx context.Context) error {
+	resp, err := sidecarGet(ctx, "/v1.0/healthz/outbound")
+	if err != nil {
+		return fmt.Errorf("sidecar is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("sidecar isn't healthy: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func fetchSidecarStatus(ctx context.Context) (*SidecarStatus, error) {
+	resp, err := sidecarGet(ctx, "/v1.0/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sidecar metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch sidecar metadata: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var metadata sidecarMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode sidecar metadata: %w", err)
+	}
+
+	status := &SidecarStatus{
+		AppID:          metadata.ID,
+		RuntimeVersion: metadata.RuntimeVersion,
+		Components:     []SidecarComponent{},
+		Subscriptions:  []SidecarSubscription{},
+		Workflows:      SidecarWorkflows{ConnectedWorkers: metadata.Workflows.ConnectedWorkers},
+		Actors: SidecarActors{
+			RuntimeStatus: metadata.ActorRuntime.RuntimeStatus,
+			HostReady:     metadata.ActorRuntime.HostReady,
+			Placement:     metadata.ActorRuntime.Placement,
+		},
+	}
+	for _, c := range metadata.Components {
+		status.Components = append(status.Components, SidecarComponent{Name: c.Name, Type: c.Type, Version: c.Version})
+	}
+	for _, s := range metadata.Subscriptions {
+		status.Subscriptions = append(status.Subscriptions, SidecarSubscription{PubsubName: s.PubsubName, Topic: s.Topic})
+	}
+	if len(metadata.ActorRuntime.ActiveActors) > 0 {
+		status.Actors.ActiveActors = make(map[string]int, len(metadata.ActorRuntime.ActiveActors))
+		for _, actor := range metadata.ActorRuntime.ActiveActors {
+			status.Actors.ActiveActors[actor.Type] = actor.Count
+		}
+	}
+	return status, nil
+}
+
+func sidecarGet(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sidecarURL(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
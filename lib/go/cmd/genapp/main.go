@@ -0,0 +1,256 @@
+// Command genapp creates a Go app in apps/ from templates/go, and loads it
+// from the root Tiltfile:
+//
+//	go -C lib/go run ./cmd/genapp -id my-app -port 6030
+//
+// The template files are text/template templates, given the app ID as
+// {{.AppID}} and its port as {{.AppPort}}.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateDir is where the template lives, relative to the repository root.
+const templateDir = "templates/go"
+
+// appIDPattern is what Kubernetes accepts as a name, which the app ID is used
+// as: lowercase alphanumerics and dashes, starting and ending with an
+// alphanumeric.
+var appIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// reservedPorts are the ports of the Dapr sidecar, which shares the pod's
+// network with the app.
+var reservedPorts = map[int]string{
+	3500:  "the Dapr HTTP API",
+	50001: "the Dapr gRPC API",
+	50002: "the Dapr internal gRPC server",
+	9090:  "the Dapr metrics",
+	8080:  "the Dapr health probes",
+}
+
+// portForwardPattern matches the local ports the apps' Tiltfiles forward.
+var portForwardPattern = regexp.MustCompile(`port_forwards=\[([^\]]*)\]`)
+
+type App struct {
+	AppID   string
+	AppPort string
+}
+
+func main() {
+	var app App
+	flag.StringVar(&app.AppID, "id", "", "the app ID, also the name of its directory in apps/")
+	flag.StringVar(&app.AppPort, "port", "", "the port the app serves HTTP on")
+	flag.Parse()
+
+	root, err := findRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := generate(root, app); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Created apps/%s, loaded from the root Tiltfile\n", app.AppID)
+}
+
+// findRoot returns the repository root, the closest directory up from the
+// working directory with the template and a Tiltfile.
+func findRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if isDir(filepath.Join(dir, templateDir)) && isFile(filepath.Join(dir, "Tiltfile")) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found up from the working directory", templateDir)
+		}
+		dir = parent
+	}
+}
+
+func generate(root string, app App) error {
+	if err := validate(root, app); err != nil {
+		return err
+	}
+
+	// Render everything before writing anything, so a broken template
+	// doesn't leave a half-generated app behind.
+	files, err := render(filepath.Join(root, templateDir), app)
+	if err != nil {
+		return err
+	}
+
+	appDir := filepath.Join(root, "apps", app.AppID)
+	if err := os.Mkdir(appDir, 0o755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("apps/%s already exists", app.AppID)
+		}
+		return err
+	}
+	if err := write(appDir, files); err != nil {
+		os.RemoveAll(appDir)
+		return err
+	}
+	if err := addToTiltfile(filepath.Join(root, "Tiltfile"), app); err != nil {
+		os.RemoveAll(appDir)
+		return err
+	}
+	return nil
+}
+
+func validate(root string, app App) error {
+	if !appIDPattern.MatchString(app.AppID) || len(app.AppID) > 63 {
+		return fmt.Errorf("invalid app ID %q: it must be at most 63 lowercase letters, digits and dashes, starting and ending with a letter or digit", app.AppID)
+	}
+	if isDir(filepath.Join(root, "apps", app.AppID)) {
+		return fmt.Errorf("apps/%s already exists", app.AppID)
+	}
+
+	port, err := strconv.Atoi(app.AppPort)
+	if err != nil || port < 1024 || port > 65535 {
+		return fmt.Errorf("invalid port %q: it must be a number from 1024 to 65535", app.AppPort)
+	}
+	if what, ok := reservedPorts[port]; ok {
+		return fmt.Errorf("port %d is taken by %s", port, what)
+	}
+	used, err := forwardedPorts(root)
+	if err != nil {
+		return err
+	}
+	if tiltfile, ok := used[app.AppPort]; ok {
+		return fmt.Errorf("port %d is already forwarded by %s", port, tiltfile)
+	}
+	return nil
+}
+
+// forwardedPorts returns the local ports forwarded by the apps' Tiltfiles,
+// with the Tiltfile forwarding each.
+func forwardedPorts(root string) (map[string]string, error) {
+	tiltfiles, err := filepath.Glob(filepath.Join(root, "apps", "*", "Tiltfile"))
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]string{}
+	for _, path := range tiltfiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(root, path)
+		for _, match := range portForwardPattern.FindAllStringSubmatch(string(content), -1) {
+			for _, forward := range strings.Split(match[1], ",") {
+				local, _, _ := strings.Cut(strings.Trim(forward, ` '"`), ":")
+				if local != "" {
+					used[local] = rel
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+type file struct {
+	path    string
+	mode    fs.FileMode
+	content []byte
+}
+
+// render renders every file of the template, keeping its path relative to
+// the template directory.
+func render(dir string, app App) ([]file, error) {
+	var files []file
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(rel).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", rel, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, app); err != nil {
+			return fmt.Errorf("failed to render %s: %w", rel, err)
+		}
+		files = append(files, file{path: rel, mode: info.Mode().Perm(), content: out.Bytes()})
+		return nil
+	})
+	return files, err
+}
+
+func write(dir string, files []file) error {
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.content, f.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addToTiltfile adds the load_dynamic line of the app to the root Tiltfile,
+// after the ones of the other apps.
+func addToTiltfile(path string, app App) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("load_dynamic('apps/%s/Tiltfile')", app.AppID)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	last := -1
+	for i, l := range lines {
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "#"))
+		if l == line {
+			return fmt.Errorf("the Tiltfile already loads apps/%s", app.AppID)
+		}
+		if strings.HasPrefix(l, "load_dynamic('apps/") {
+			last = i
+		}
+	}
+	if last == -1 {
+		lines = append(lines, "", line)
+	} else {
+		lines = append(lines[:last+1], append([]string{line}, lines[last+1:]...)...)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
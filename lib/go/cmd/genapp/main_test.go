@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to path under root, creating its directories.
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "apps/existing/Tiltfile", "k8s_resource('existing', port_forwards=['6001:6001', \"6002:8080\"])\n")
+
+	tests := []struct {
+		name    string
+		app     App
+		wantErr string
+	}{
+		{name: "valid", app: App{AppID: "my-app", AppPort: "6030"}},
+		{name: "valid digits", app: App{AppID: "app2", AppPort: "1024"}},
+		{name: "empty ID", app: App{AppID: "", AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "uppercase ID", app: App{AppID: "My-App", AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "underscore in ID", app: App{AppID: "my_app", AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "leading dash", app: App{AppID: "-app", AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "trailing dash", app: App{AppID: "app-", AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "ID too long", app: App{AppID: strings.Repeat("a", 64), AppPort: "6030"}, wantErr: "invalid app ID"},
+		{name: "existing app", app: App{AppID: "existing", AppPort: "6030"}, wantErr: "apps/existing already exists"},
+		{name: "port not a number", app: App{AppID: "my-app", AppPort: "http"}, wantErr: "invalid port"},
+		{name: "privileged port", app: App{AppID: "my-app", AppPort: "80"}, wantErr: "invalid port"},
+		{name: "port out of range", app: App{AppID: "my-app", AppPort: "70000"}, wantErr: "invalid port"},
+		{name: "Dapr HTTP port", app: App{AppID: "my-app", AppPort: "3500"}, wantErr: "taken by the Dapr HTTP API"},
+		{name: "Dapr gRPC port", app: App{AppID: "my-app", AppPort: "50001"}, wantErr: "taken by the Dapr gRPC API"},
+		{name: "forwarded port", app: App{AppID: "my-app", AppPort: "6001"}, wantErr: "already forwarded by apps/existing/Tiltfile"},
+		{name: "forwarded port, double quoted", app: App{AppID: "my-app", AppPort: "6002"}, wantErr: "already forwarded by apps/existing/Tiltfile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(root, tt.app)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate() = %v, want no error", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("validate() = nil, want an error with %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("validate() = %v, want an error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddToTiltfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "no load_dynamic lines",
+			content: "load('ext://restart_process', 'docker_build_with_restart')\n",
+			want:    "load('ext://restart_process', 'docker_build_with_restart')\n\nload_dynamic('apps/my-app/Tiltfile')\n",
+		},
+		{
+			name:    "after the last load_dynamic line",
+			content: "load_dynamic('apps/a/Tiltfile')\nload_dynamic('apps/b/Tiltfile')\n\nlocal_resource('x', 'true')\n",
+			want:    "load_dynamic('apps/a/Tiltfile')\nload_dynamic('apps/b/Tiltfile')\nload_dynamic('apps/my-app/Tiltfile')\n\nlocal_resource('x', 'true')\n",
+		},
+		{
+			name:    "after a commented out load_dynamic line",
+			content: "load_dynamic('apps/a/Tiltfile')\n# load_dynamic('apps/b/Tiltfile')\n",
+			want:    "load_dynamic('apps/a/Tiltfile')\n# load_dynamic('apps/b/Tiltfile')\nload_dynamic('apps/my-app/Tiltfile')\n",
+		},
+		{
+			name:    "already loaded",
+			content: "load_dynamic('apps/my-app/Tiltfile')\n",
+			wantErr: "already loads apps/my-app",
+		},
+		{
+			name:    "already loaded, commented out",
+			content: "#load_dynamic('apps/my-app/Tiltfile')\n",
+			wantErr: "already loads apps/my-app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Tiltfile")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := addToTiltfile(path, App{AppID: "my-app", AppPort: "6030"})
+			got, readErr := os.ReadFile(path)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("addToTiltfile() = %v, want an error with %q", err, tt.wantErr)
+				}
+				if string(got) != tt.content {
+					t.Fatalf("addToTiltfile() changed the Tiltfile on error:\n%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("addToTiltfile() = %v, want no error", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Tiltfile is\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
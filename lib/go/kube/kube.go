@@ -0,0 +1,153 @@
+// Package kube is a minimal in-cluster client for the Kubernetes API, enough
+// for the scenarios to list, delete and scale the pods of the other apps and
+// of the control plane. It authenticates with the pod's service account, see
+// the manifests/rbac.yaml of each app for the permissions it needs.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+type Client struct {
+	host       string
+	token      string
+	httpClient *http.Client
+}
+
+type Pod struct {
+	Metadata struct {
+		Name              string  `json:"name"`
+		UID               string  `json:"uid"`
+		DeletionTimestamp *string `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// Ready reports whether the pod is ready and not being deleted.
+func (p Pod) Ready() bool {
+	if p.Metadata.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// NewInClusterClient returns a client for the cluster the app runs in.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a Kubernetes cluster")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+
+	return &Client{
+		host:  "https://" + host + ":" + port,
+		token: strings.TrimSpace(string(token)),
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// Namespace returns the namespace the app runs in, "default" outside a
+// cluster.
+func Namespace() string {
+	if ns, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "default"
+}
+
+// do sends the request, with patch, if not nil, as a JSON merge patch.
+func (c *Client) do(ctx context.Context, method, path string, patch, out any) error {
+	var body io.Reader
+	if patch != nil {
+		encoded, err := json.Marshal(patch)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if patch != nil {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, string(body))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) ListPods(ctx context.Context, namespace, labelSelector string) ([]Pod, error) {
+	var list struct {
+		Items []Pod `json:"items"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s", namespace, url.QueryEscape(labelSelector))
+	if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// DeletePod deletes a pod with its own grace period, which the app gets to
+// shut down gracefully in.
+func (c *Client) DeletePod(ctx context.Context, namespace, name string) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", namespace, name), nil, nil)
+}
+
+// KillPod deletes a pod without a grace period, like a crash would, skipping
+// the graceful shutdown of the app.
+func (c *Client) KillPod(ctx context.Context, namespace, name string) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s?gracePeriodSeconds=0", namespace, name), nil, nil)
+}
+
+func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, replicas int) error {
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s/scale", namespace, name)
+	return c.do(ctx, http.MethodPatch, path, map[string]any{"spec": map[string]any{"replicas": replicas}}, nil)
+}
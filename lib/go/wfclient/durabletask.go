@@ -0,0 +1,97 @@
+package wfclient
+
+import (
+	"context"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+	dapr "github.com/dapr/go-sdk/client"
+)
+
+type durableTaskClient struct {
+	client *workflow.Client
+}
+
+func newDurableTaskClient(daprClient dapr.Client) *durableTaskClient {
+	return &durableTaskClient{client: workflow.NewClient(daprClient.GrpcClientConn())}
+}
+
+func (c *durableTaskClient) Backend() Backend { return BackendDurableTask }
+
+func (c *durableTaskClient) ScheduleWorkflow(ctx context.Context, name string, opts ...ScheduleOption) (string, error) {
+	return c.client.ScheduleWorkflow(ctx, name, convert[workflow.NewWorkflowOptions](collect(opts))...)
+}
+
+func (c *durableTaskClient) FetchWorkflowMetadata(ctx context.Context, id string) (*Metadata, error) {
+	return fromDurableTask(c.client.FetchWorkflowMetadata(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *durableTaskClient) WaitForWorkflowStart(ctx context.Context, id string) (*Metadata, error) {
+	return fromDurableTask(c.client.WaitForWorkflowStart(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *durableTaskClient) WaitForWorkflowCompletion(ctx context.Context, id string) (*Metadata, error) {
+	return fromDurableTask(c.client.WaitForWorkflowCompletion(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *durableTaskClient) RaiseEvent(ctx context.Context, id, name string, opts ...RaiseEventOption) error {
+	return normalizeError(c.client.RaiseEvent(ctx, id, name, convert[workflow.RaiseEventOptions](collect(opts))...))
+}
+
+func (c *durableTaskClient) SuspendWorkflow(ctx context.Context, id, reason string) error {
+	return normalizeError(c.client.SuspendWorkflow(ctx, id, reason))
+}
+
+func (c *durableTaskClient) ResumeWorkflow(ctx context.Context, id, reason string) error {
+	return normalizeError(c.client.ResumeWorkflow(ctx, id, reason))
+}
+
+func (c *durableTaskClient) TerminateWorkflow(ctx context.Context, id string, opts ...TerminateOption) error {
+	return normalizeError(c.client.TerminateWorkflow(ctx, id, convert[workflow.TerminateOptions](collect(opts))...))
+}
+
+func (c *durableTaskClient) PurgeWorkflow(ctx context.Context, id string, opts ...PurgeOption) error {
+	return normalizeError(c.client.PurgeWorkflowState(ctx, id, convert[workflow.PurgeOptions](collect(opts))...))
+}
+
+// convert turns api options into the named types the durabletask-go workflow
+// package declares over them.
+func convert[O ~func(*P) error, A ~func(*P) error, P any](opts []A) []O {
+	out := make([]O, len(opts))
+	for i, o := range opts {
+		out[i] = O(o)
+	}
+	return out
+}
+
+func fromDurableTask(wm *workflow.WorkflowMetadata, err error) (*Metadata, error) {
+	if err != nil {
+		return nil, normalizeError(err)
+	}
+	m := (*protos.OrchestrationMetadata)(wm)
+	return &Metadata{
+		InstanceID:       m.GetInstanceId(),
+		Name:             m.GetName(),
+		Status:           fromRuntimeStatus(m.GetRuntimeStatus()),
+		ParentInstanceID: m.GetParentInstanceId(),
+		CreatedAt:        m.GetCreatedAt().AsTime(),
+		LastUpdatedAt:    m.GetLastUpdatedAt().AsTime(),
+		Input:            m.GetInput().GetValue(),
+		Output:           m.GetOutput().GetValue(),
+		CustomStatus:     m.GetCustomStatus().GetValue(),
+		FailureDetails:   fromTaskFailure(m.GetFailureDetails()),
+	}, nil
+}
+
+func fromTaskFailure(f *protos.TaskFailureDetails) *FailureDetails {
+	if f == nil {
+		return nil
+	}
+	return &FailureDetails{
+		ErrorType:      f.GetErrorType(),
+		ErrorMessage:   f.GetErrorMessage(),
+		StackTrace:     f.GetStackTrace().GetValue(),
+		InnerFailure:   fromTaskFailure(f.GetInnerFailure()),
+		IsNonRetriable: f.GetIsNonRetriable(),
+	}
+}
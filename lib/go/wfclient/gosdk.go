@@ -0,0 +1,89 @@
+package wfclient
+
+import (
+	"context"
+
+	dapr "github.com/dapr/go-sdk/client"
+	"github.com/dapr/go-sdk/workflow"
+)
+
+type goSDKClient struct {
+	client *workflow.Client
+}
+
+func newGoSDKClient(daprClient dapr.Client) (*goSDKClient, error) {
+	//nolint:staticcheck // The deprecated client is the one being compared.
+	client, err := workflow.NewClient(workflow.WithDaprClient(daprClient))
+	if err != nil {
+		return nil, err
+	}
+	return &goSDKClient{client: client}, nil
+}
+
+func (c *goSDKClient) Backend() Backend { return BackendGoSDK }
+
+func (c *goSDKClient) ScheduleWorkflow(ctx context.Context, name string, opts ...ScheduleOption) (string, error) {
+	return c.client.ScheduleNewWorkflow(ctx, name, collect(opts)...)
+}
+
+func (c *goSDKClient) FetchWorkflowMetadata(ctx context.Context, id string) (*Metadata, error) {
+	return fromGoSDK(c.client.FetchWorkflowMetadata(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *goSDKClient) WaitForWorkflowStart(ctx context.Context, id string) (*Metadata, error) {
+	return fromGoSDK(c.client.WaitForWorkflowStart(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *goSDKClient) WaitForWorkflowCompletion(ctx context.Context, id string) (*Metadata, error) {
+	return fromGoSDK(c.client.WaitForWorkflowCompletion(ctx, id, workflow.WithFetchPayloads(true)))
+}
+
+func (c *goSDKClient) RaiseEvent(ctx context.Context, id, name string, opts ...RaiseEventOption) error {
+	return normalizeError(c.client.RaiseEvent(ctx, id, name, collect(opts)...))
+}
+
+func (c *goSDKClient) SuspendWorkflow(ctx context.Context, id, reason string) error {
+	return normalizeError(c.client.SuspendWorkflow(ctx, id, reason))
+}
+
+func (c *goSDKClient) ResumeWorkflow(ctx context.Context, id, reason string) error {
+	return normalizeError(c.client.ResumeWorkflow(ctx, id, reason))
+}
+
+func (c *goSDKClient) TerminateWorkflow(ctx context.Context, id string, opts ...TerminateOption) error {
+	return normalizeError(c.client.TerminateWorkflow(ctx, id, collect(opts)...))
+}
+
+func (c *goSDKClient) PurgeWorkflow(ctx context.Context, id string, opts ...PurgeOption) error {
+	return normalizeError(c.client.PurgeWorkflow(ctx, id, collect(opts)...))
+}
+
+func fromGoSDK(m *workflow.Metadata, err error) (*Metadata, error) {
+	if err != nil {
+		return nil, normalizeError(err)
+	}
+	return &Metadata{
+		InstanceID:     m.InstanceID,
+		Name:           m.Name,
+		Status:         ParseStatus(m.RuntimeStatus.String()),
+		CreatedAt:      m.CreatedAt,
+		LastUpdatedAt:  m.LastUpdatedAt,
+		Input:          m.SerializedInput,
+		Output:         m.SerializedOutput,
+		CustomStatus:   m.SerializedCustomStatus,
+		FailureDetails: fromGoSDKFailure(m.FailureDetails),
+	}, nil
+}
+
+func fromGoSDKFailure(f *workflow.FailureDetails) *FailureDetails {
+	if f == nil {
+		return nil
+	}
+	return &FailureDetails{
+		ErrorType:      f.Type,
+		ErrorMessage:   f.Message,
+		StackTrace:     f.StackTrace,
+		InnerFailure:   fromGoSDKFailure(f.InnerFailure),
+		IsNonRetriable: f.IsNonRetriable,
+	}
+}
@@ -0,0 +1,101 @@
+package wfclient
+
+import (
+	"github.com/dapr/durabletask-go/api"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The options of both SDKs boil down to the durabletask-go api options, so
+// the options here are built from those.
+
+type ScheduleOption func(*[]api.NewOrchestrationOptions)
+
+// WithInstanceID sets the ID of the instance, the runtime generates one
+// otherwise.
+func WithInstanceID(id string) ScheduleOption {
+	return func(opts *[]api.NewOrchestrationOptions) {
+		*opts = append(*opts, api.WithInstanceID(api.InstanceID(id)))
+	}
+}
+
+// WithInput sets the input of the instance, encoded as JSON.
+func WithInput(input any) ScheduleOption {
+	return func(opts *[]api.NewOrchestrationOptions) {
+		*opts = append(*opts, api.WithInput(input))
+	}
+}
+
+// WithRawInput sets the input of the instance, already encoded.
+func WithRawInput(input string) ScheduleOption {
+	return func(opts *[]api.NewOrchestrationOptions) {
+		*opts = append(*opts, api.WithRawInput(wrapperspb.String(input)))
+	}
+}
+
+// WithReuseIDPolicy sets what to do if an instance with the same ID already
+// exists in one of the given statuses.
+func WithReuseIDPolicy(action api.CreateOrchestrationAction, statuses ...Status) ScheduleOption {
+	policy := &api.OrchestrationIdReusePolicy{Action: action}
+	for _, s := range statuses {
+		policy.OperationStatus = append(policy.OperationStatus, s.runtimeStatus())
+	}
+	return func(opts *[]api.NewOrchestrationOptions) {
+		*opts = append(*opts, api.WithOrchestrationIdReusePolicy(policy))
+	}
+}
+
+type RaiseEventOption func(*[]api.RaiseEventOptions)
+
+// WithEventPayload sets the payload of the event, encoded as JSON.
+func WithEventPayload(data any) RaiseEventOption {
+	return func(opts *[]api.RaiseEventOptions) {
+		*opts = append(*opts, api.WithEventPayload(data))
+	}
+}
+
+// WithRawEventData sets the payload of the event, already encoded.
+func WithRawEventData(data string) RaiseEventOption {
+	return func(opts *[]api.RaiseEventOptions) {
+		*opts = append(*opts, api.WithRawEventData(wrapperspb.String(data)))
+	}
+}
+
+type TerminateOption func(*[]api.TerminateOptions)
+
+// WithOutput sets the output of the terminated instance, encoded as JSON.
+func WithOutput(data any) TerminateOption {
+	return func(opts *[]api.TerminateOptions) {
+		*opts = append(*opts, api.WithOutput(data))
+	}
+}
+
+// WithRawOutput sets the output of the terminated instance, already encoded.
+func WithRawOutput(data string) TerminateOption {
+	return func(opts *[]api.TerminateOptions) {
+		*opts = append(*opts, api.WithRawOutput(wrapperspb.String(data)))
+	}
+}
+
+// WithRecursiveTerminate sets whether the children are terminated too.
+func WithRecursiveTerminate(recursive bool) TerminateOption {
+	return func(opts *[]api.TerminateOptions) {
+		*opts = append(*opts, api.WithRecursiveTerminate(recursive))
+	}
+}
+
+type PurgeOption func(*[]api.PurgeOptions)
+
+// WithRecursivePurge sets whether the children are purged too.
+func WithRecursivePurge(recursive bool) PurgeOption {
+	return func(opts *[]api.PurgeOptions) {
+		*opts = append(*opts, api.WithRecursivePurge(recursive))
+	}
+}
+
+func collect[T any, O ~func(*[]T)](opts []O) []T {
+	var out []T
+	for _, o := range opts {
+		o(&out)
+	}
+	return out
+}
@@ -0,0 +1,164 @@
+// Package wfclient is the workflow client of the Go apps. It puts the two Go
+// SDKs the apps use behind one interface and one status model, so the same
+// scenarios can run through either of them:
+//   - BackendGoSDK uses github.com/dapr/go-sdk/workflow.
+//   - BackendDurableTask uses github.com/dapr/durabletask-go/workflow, the
+//     client go-sdk's client.NewWorkflowClient returns.
+//
+// Only the client side is covered. Workflows and activities are written
+// against one SDK or the other, so each app keeps running its worker with the
+// SDK its workflows use.
+package wfclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dapr/durabletask-go/api"
+	"github.com/dapr/durabletask-go/api/protos"
+	dapr "github.com/dapr/go-sdk/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backend is the SDK a Client goes through.
+type Backend string
+
+const (
+	BackendGoSDK       Backend = "go-sdk"
+	BackendDurableTask Backend = "durabletask"
+)
+
+// BackendEnv is the environment variable BackendFromEnv reads.
+const BackendEnv = "WORKFLOW_CLIENT_BACKEND"
+
+// BackendFromEnv returns the backend set in BackendEnv, or def if it's unset.
+func BackendFromEnv(def Backend) (Backend, error) {
+	switch b := Backend(os.Getenv(BackendEnv)); b {
+	case "":
+		return def, nil
+	case BackendGoSDK, BackendDurableTask:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown %s %q, expected %q or %q", BackendEnv, b, BackendGoSDK, BackendDurableTask)
+	}
+}
+
+// Status is the runtime status of an instance, e.g. "COMPLETED", whichever
+// backend reported it.
+type Status string
+
+const (
+	StatusRunning        Status = "RUNNING"
+	StatusCompleted      Status = "COMPLETED"
+	StatusContinuedAsNew Status = "CONTINUED_AS_NEW"
+	StatusFailed         Status = "FAILED"
+	StatusCanceled       Status = "CANCELED"
+	StatusTerminated     Status = "TERMINATED"
+	StatusPending        Status = "PENDING"
+	StatusSuspended      Status = "SUSPENDED"
+	StatusUnknown        Status = "UNKNOWN"
+)
+
+// ParseStatus accepts the status names of both SDKs, with or without the
+// "ORCHESTRATION_STATUS_" prefix of the protobuf enum.
+func ParseStatus(s string) Status {
+	s = strings.TrimPrefix(strings.ToUpper(s), "ORCHESTRATION_STATUS_")
+	switch st := Status(s); st {
+	case StatusRunning, StatusCompleted, StatusContinuedAsNew, StatusFailed, StatusCanceled, StatusTerminated, StatusPending, StatusSuspended:
+		return st
+	}
+	return StatusUnknown
+}
+
+func fromRuntimeStatus(s protos.OrchestrationStatus) Status {
+	return ParseStatus(s.String())
+}
+
+func (s Status) runtimeStatus() protos.OrchestrationStatus {
+	return protos.OrchestrationStatus(protos.OrchestrationStatus_value["ORCHESTRATION_STATUS_"+string(s)])
+}
+
+// IsTerminal reports whether an instance in this status is done running.
+func (s Status) IsTerminal() bool {
+	switch s {
+	case StatusCompleted, StatusFailed, StatusCanceled, StatusTerminated:
+		return true
+	}
+	return false
+}
+
+type FailureDetails struct {
+	ErrorType      string          `json:"error_type"`
+	ErrorMessage   string          `json:"error_message"`
+	StackTrace     string          `json:"stack_trace,omitempty"`
+	InnerFailure   *FailureDetails `json:"inner_failure,omitempty"`
+	IsNonRetriable bool            `json:"is_non_retriable,omitempty"`
+}
+
+// Metadata is the state of an instance, always with its payloads.
+type Metadata struct {
+	InstanceID string `json:"instance_id"`
+	Name       string `json:"name"`
+	Status     Status `json:"runtime_status"`
+	// ParentInstanceID is only reported by BackendDurableTask.
+	ParentInstanceID string          `json:"parent_instance_id,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	LastUpdatedAt    time.Time       `json:"last_updated_at"`
+	Input            string          `json:"input,omitempty"`
+	Output           string          `json:"output,omitempty"`
+	CustomStatus     string          `json:"custom_status,omitempty"`
+	FailureDetails   *FailureDetails `json:"failure_details,omitempty"`
+}
+
+// IsComplete reports whether the instance is done running.
+func (m *Metadata) IsComplete() bool {
+	return m.Status.IsTerminal()
+}
+
+// ErrNotFound is returned, wrapped, for instances that don't exist.
+var ErrNotFound = api.ErrInstanceNotFound
+
+// Client manages workflow instances. Workflows are referred to by their
+// registered name.
+type Client interface {
+	Backend() Backend
+	ScheduleWorkflow(ctx context.Context, name string, opts ...ScheduleOption) (string, error)
+	FetchWorkflowMetadata(ctx context.Context, id string) (*Metadata, error)
+	WaitForWorkflowStart(ctx context.Context, id string) (*Metadata, error)
+	WaitForWorkflowCompletion(ctx context.Context, id string) (*Metadata, error)
+	RaiseEvent(ctx context.Context, id, name string, opts ...RaiseEventOption) error
+	SuspendWorkflow(ctx context.Context, id, reason string) error
+	ResumeWorkflow(ctx context.Context, id, reason string) error
+	TerminateWorkflow(ctx context.Context, id string, opts ...TerminateOption) error
+	PurgeWorkflow(ctx context.Context, id string, opts ...PurgeOption) error
+}
+
+// New returns a Client going through the given backend, on the connection of
+// the Dapr client. Closing the Dapr client closes it.
+func New(backend Backend, daprClient dapr.Client) (Client, error) {
+	switch backend {
+	case BackendGoSDK:
+		return newGoSDKClient(daprClient)
+	case BackendDurableTask:
+		return newDurableTaskClient(daprClient), nil
+	default:
+		return nil, fmt.Errorf("unknown workflow client backend %q", backend)
+	}
+}
+
+// normalizeError makes missing instances ErrNotFound, however the backend
+// reports them.
+func normalizeError(err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+// Package wfhistory fetches the history of workflow instances from the
+// sidecar, and renders it as a timeline.
+package wfhistory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUnsupported is returned when the sidecar doesn't implement
+// StreamInstanceHistory.
+var ErrUnsupported = errors.New("the sidecar doesn't support fetching workflow history")
+
+// Fetch fetches the full history of a workflow instance of the app, through
+// the gRPC connection to its sidecar.
+func Fetch(ctx context.Context, conn grpc.ClientConnInterface, id string) ([]*protos.HistoryEvent, error) {
+	client := protos.NewTaskHubSidecarServiceClient(conn)
+	stream, err := client.StreamInstanceHistory(ctx, &protos.StreamInstanceHistoryRequest{InstanceId: id})
+	if err != nil {
+		return nil, fetchError(err)
+	}
+
+	var events []*protos.HistoryEvent
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fetchError(err)
+		}
+		events = append(events, chunk.GetEvents()...)
+	}
+}
+
+func fetchError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return ErrUnsupported
+	}
+	return fmt.Errorf("failed to fetch workflow history: %w", err)
+}
+
+// EventType returns the name of the event type, e.g. "TaskScheduled".
+func EventType(e *protos.HistoryEvent) string {
+	name := fmt.Sprintf("%T", e.GetEventType())
+	return strings.TrimPrefix(name, "*protos.HistoryEvent_")
+}
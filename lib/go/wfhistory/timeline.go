@@ -0,0 +1,195 @@
+package wfhistory
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// Entry is a history event as the timeline shows it.
+type Entry struct {
+	EventID   int32     `json:"event_id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// Elapsed is the time since the first event, Gap the time since the
+	// previous one.
+	Elapsed time.Duration `json:"elapsed"`
+	Gap     time.Duration `json:"gap"`
+	// Name is the activity, workflow, timer or event the entry is about.
+	Name string `json:"name,omitempty"`
+	// AppID is the app an activity or child workflow ran in, when the history
+	// records it. Results carry the app of the call they complete.
+	AppID  string `json:"app_id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Timeline turns the history of an instance into timeline entries.
+func Timeline(events []*protos.HistoryEvent) []Entry {
+	entries := make([]Entry, 0, len(events))
+	// The calls by event ID, so results can show what they complete.
+	calls := map[int32]Entry{}
+	var first, prev time.Time
+	for i, e := range events {
+		ts := e.GetTimestamp().AsTime()
+		if i == 0 {
+			first, prev = ts, ts
+		}
+		entry := Entry{
+			EventID:   e.GetEventId(),
+			Type:      EventType(e),
+			Timestamp: ts,
+			Elapsed:   ts.Sub(first),
+			Gap:       ts.Sub(prev),
+			AppID:     e.GetRouter().GetTargetAppID(),
+		}
+		prev = ts
+
+		completes := func(id int32) {
+			call := calls[id]
+			entry.Name = call.Name
+			if entry.AppID == "" {
+				entry.AppID = call.AppID
+			}
+			entry.Detail = fmt.Sprintf("completes event %d, after %s", id, ts.Sub(call.Timestamp).Round(time.Millisecond))
+		}
+		switch {
+		case e.GetExecutionStarted() != nil:
+			entry.Name = e.GetExecutionStarted().GetName()
+			if parent := e.GetExecutionStarted().GetParentInstance(); parent != nil {
+				entry.Detail = "child of " + parent.GetOrchestrationInstance().GetInstanceId()
+			}
+		case e.GetExecutionCompleted() != nil:
+			completed := e.GetExecutionCompleted()
+			entry.Detail = strings.TrimPrefix(completed.GetOrchestrationStatus().String(), "ORCHESTRATION_STATUS_")
+			if f := completed.GetFailureDetails(); f != nil {
+				entry.Detail += ": " + f.GetErrorMessage()
+			}
+		case e.GetTaskScheduled() != nil:
+			entry.Name = e.GetTaskScheduled().GetName()
+			calls[entry.EventID] = entry
+		case e.GetTaskCompleted() != nil:
+			completes(e.GetTaskCompleted().GetTaskScheduledId())
+		case e.GetTaskFailed() != nil:
+			completes(e.GetTaskFailed().GetTaskScheduledId())
+			entry.Detail += ": " + e.GetTaskFailed().GetFailureDetails().GetErrorMessage()
+		case e.GetSubOrchestrationInstanceCreated() != nil:
+			created := e.GetSubOrchestrationInstanceCreated()
+			entry.Name = created.GetName()
+			entry.Detail = "instance " + created.GetInstanceId()
+			calls[entry.EventID] = entry
+		case e.GetSubOrchestrationInstanceCompleted() != nil:
+			completes(e.GetSubOrchestrationInstanceCompleted().GetTaskScheduledId())
+		case e.GetSubOrchestrationInstanceFailed() != nil:
+			completes(e.GetSubOrchestrationInstanceFailed().GetTaskScheduledId())
+			entry.Detail += ": " + e.GetSubOrchestrationInstanceFailed().GetFailureDetails().GetErrorMessage()
+		case e.GetTimerCreated() != nil:
+			entry.Detail = "fires at " + e.GetTimerCreated().GetFireAt().AsTime().Format(time.RFC3339Nano)
+			calls[entry.EventID] = entry
+		case e.GetTimerFired() != nil:
+			completes(e.GetTimerFired().GetTimerId())
+		case e.GetEventRaised() != nil:
+			entry.Name = e.GetEventRaised().GetName()
+		case e.GetEventSent() != nil:
+			entry.Name = e.GetEventSent().GetName()
+			entry.Detail = "to " + e.GetEventSent().GetInstanceId()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// WriteText writes the timeline as an aligned table.
+func WriteText(w io.Writer, id string, entries []Entry) error {
+	fmt.Fprintf(w, "History of %s, %d events\n\n", id, len(entries))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tELAPSED\tGAP\tTYPE\tNAME\tAPP\tDETAIL")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t+%s\t%s\t%s\t%s\t%s\n",
+			e.EventID, e.Timestamp.Format("15:04:05.000"), e.Elapsed.Round(time.Millisecond), e.Gap.Round(time.Millisecond),
+			e.Type, e.Name, e.AppID, e.Detail)
+	}
+	return tw.Flush()
+}
+
+var timelineHTML = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	// width scales gaps to a bar, so long waits stand out.
+	"width": func(gap, total time.Duration) int {
+		if total <= 0 {
+			return 0
+		}
+		return int(200 * gap / total)
+	},
+	"failed": func(e Entry) bool {
+		return strings.HasSuffix(e.Type, "Failed") || strings.Contains(e.Detail, "FAILED")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>History of {{.ID}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 8px; text-align: left; border-bottom: 1px solid #ddd; }
+td.num { text-align: right; font-family: monospace; }
+tr.failed { background: #fdd; }
+.bar { display: inline-block; height: 10px; background: #69c; }
+</style>
+</head>
+<body>
+<h1>History of {{.ID}}</h1>
+<p>{{len .Entries}} events over {{ms .Total}}</p>
+<table>
+<tr><th>ID</th><th>Time</th><th>Elapsed</th><th>Gap</th><th></th><th>Type</th><th>Name</th><th>App</th><th>Detail</th></tr>
+{{- range .Entries}}
+<tr{{if failed .}} class="failed"{{end}}>
+<td class="num">{{.EventID}}</td>
+<td class="num">{{.Timestamp.Format "15:04:05.000"}}</td>
+<td class="num">{{ms .Elapsed}}</td>
+<td class="num">+{{ms .Gap}}</td>
+<td><span class="bar" style="width: {{width .Gap $.Total}}px"></span></td>
+<td>{{.Type}}</td>
+<td>{{.Name}}</td>
+<td>{{.AppID}}</td>
+<td>{{.Detail}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the timeline as a page, with a bar per gap.
+func WriteHTML(w io.Writer, id string, entries []Entry) error {
+	var total time.Duration
+	if len(entries) > 0 {
+		total = entries[len(entries)-1].Elapsed
+	}
+	return timelineHTML.Execute(w, struct {
+		ID      string
+		Entries []Entry
+		Total   time.Duration
+	}{id, entries, total})
+}
+
+// Render writes the timeline of the instance as the response: as text, or as
+// a page with format=html or when a browser asks for HTML.
+func Render(w http.ResponseWriter, r *http.Request, id string, events []*protos.HistoryEvent) error {
+	entries := Timeline(events)
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		format = "html"
+	}
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return WriteHTML(w, id, entries)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	return WriteText(w, id, entries)
+}
@@ -0,0 +1,111 @@
+package wfhistory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var start = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// event builds a history event with the given ID, happening at start plus
+// offset.
+func event(id int32, offset time.Duration, e *protos.HistoryEvent) *protos.HistoryEvent {
+	e.EventId = id
+	e.Timestamp = timestamppb.New(start.Add(offset))
+	return e
+}
+
+func TestTimeline(t *testing.T) {
+	app2 := "app2"
+	tests := []struct {
+		name   string
+		events []*protos.HistoryEvent
+		want   []Entry
+	}{
+		{
+			name: "empty",
+			want: []Entry{},
+		},
+		{
+			name: "activity",
+			events: []*protos.HistoryEvent{
+				event(-1, 0, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionStarted{
+					ExecutionStarted: &protos.ExecutionStartedEvent{Name: "Workflow"},
+				}}),
+				event(0, 100*time.Millisecond, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskScheduled{
+					TaskScheduled: &protos.TaskScheduledEvent{Name: "Activity"},
+				}}),
+				event(-1, 1500*time.Millisecond, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskCompleted{
+					TaskCompleted: &protos.TaskCompletedEvent{TaskScheduledId: 0},
+				}}),
+				event(1, 2*time.Second, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionCompleted{
+					ExecutionCompleted: &protos.ExecutionCompletedEvent{OrchestrationStatus: protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED},
+				}}),
+			},
+			want: []Entry{
+				{EventID: -1, Type: "ExecutionStarted", Timestamp: start, Name: "Workflow"},
+				{EventID: 0, Type: "TaskScheduled", Timestamp: start.Add(100 * time.Millisecond), Elapsed: 100 * time.Millisecond, Gap: 100 * time.Millisecond, Name: "Activity"},
+				{EventID: -1, Type: "TaskCompleted", Timestamp: start.Add(1500 * time.Millisecond), Elapsed: 1500 * time.Millisecond, Gap: 1400 * time.Millisecond, Name: "Activity", Detail: "completes event 0, after 1.4s"},
+				{EventID: 1, Type: "ExecutionCompleted", Timestamp: start.Add(2 * time.Second), Elapsed: 2 * time.Second, Gap: 500 * time.Millisecond, Detail: "COMPLETED"},
+			},
+		},
+		{
+			name: "failed cross-app activity",
+			events: []*protos.HistoryEvent{
+				event(-1, 0, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionStarted{
+					ExecutionStarted: &protos.ExecutionStartedEvent{Name: "Workflow"},
+				}}),
+				event(0, time.Second, &protos.HistoryEvent{
+					Router:    &protos.TaskRouter{TargetAppID: &app2},
+					EventType: &protos.HistoryEvent_TaskScheduled{TaskScheduled: &protos.TaskScheduledEvent{Name: "Activity2"}},
+				}),
+				event(-1, 3*time.Second, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskFailed{
+					TaskFailed: &protos.TaskFailedEvent{TaskScheduledId: 0, FailureDetails: &protos.TaskFailureDetails{ErrorMessage: "boom"}},
+				}}),
+				event(1, 3*time.Second, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionCompleted{
+					ExecutionCompleted: &protos.ExecutionCompletedEvent{
+						OrchestrationStatus: protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED,
+						FailureDetails:      &protos.TaskFailureDetails{ErrorMessage: "activity failed"},
+					},
+				}}),
+			},
+			want: []Entry{
+				{EventID: -1, Type: "ExecutionStarted", Timestamp: start, Name: "Workflow"},
+				{EventID: 0, Type: "TaskScheduled", Timestamp: start.Add(time.Second), Elapsed: time.Second, Gap: time.Second, Name: "Activity2", AppID: "app2"},
+				{EventID: -1, Type: "TaskFailed", Timestamp: start.Add(3 * time.Second), Elapsed: 3 * time.Second, Gap: 2 * time.Second, Name: "Activity2", AppID: "app2", Detail: "completes event 0, after 2s: boom"},
+				{EventID: 1, Type: "ExecutionCompleted", Timestamp: start.Add(3 * time.Second), Elapsed: 3 * time.Second, Detail: "FAILED: activity failed"},
+			},
+		},
+		{
+			name: "timer",
+			events: []*protos.HistoryEvent{
+				event(-1, 0, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionStarted{
+					ExecutionStarted: &protos.ExecutionStartedEvent{Name: "Workflow"},
+				}}),
+				event(0, 0, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TimerCreated{
+					TimerCreated: &protos.TimerCreatedEvent{FireAt: timestamppb.New(start.Add(5 * time.Second))},
+				}}),
+				event(-1, 5250*time.Millisecond, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TimerFired{
+					TimerFired: &protos.TimerFiredEvent{TimerId: 0},
+				}}),
+			},
+			want: []Entry{
+				{EventID: -1, Type: "ExecutionStarted", Timestamp: start, Name: "Workflow"},
+				{EventID: 0, Type: "TimerCreated", Timestamp: start, Detail: "fires at 2025-01-02T03:04:10Z"},
+				{EventID: -1, Type: "TimerFired", Timestamp: start.Add(5250 * time.Millisecond), Elapsed: 5250 * time.Millisecond, Gap: 5250 * time.Millisecond, Detail: "completes event 0, after 5.25s"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Timeline(tt.events)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Timeline() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
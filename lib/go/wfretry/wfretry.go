@@ -0,0 +1,26 @@
+// Package wfretry computes the retries of a durabletask-go retry policy, for
+// scenarios to check the retries they see against it.
+package wfretry
+
+import (
+	"math"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Delay returns how long policy waits before retrying after the given failed
+// attempt, counting from 0, the way durabletask-go computes it: in whole
+// milliseconds, with a BackoffCoefficient of 1 if unset and no cap if
+// MaxRetryInterval is unset.
+func Delay(policy workflow.RetryPolicy, attempt int) time.Duration {
+	backoff := policy.BackoffCoefficient
+	if backoff <= 0 {
+		backoff = 1
+	}
+	delay := time.Duration(float64(policy.InitialRetryInterval.Milliseconds())*math.Pow(backoff, float64(attempt))) * time.Millisecond
+	if policy.MaxRetryInterval > 0 && delay >= policy.MaxRetryInterval {
+		return policy.MaxRetryInterval
+	}
+	return delay
+}
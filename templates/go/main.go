@@ -1,62 +1,31 @@
 package main
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
-	"os"
-	"time"
+
+	"github.com/acroca/dapr-example-app/lib/go/appkit"
 )
 
 type WorkflowRequest struct {
 	Input string `json:"input,omitempty"`
 }
 
-type HealthResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
-}
-
-type WorkflowResponse struct {
-	Status     string `json:"status"`
-	InstanceID string `json:"instance_id"`
-	Result     string `json:"result,omitempty"`
-	Message    string `json:"message,omitempty"`
-	Error      string `json:"error,omitempty"`
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	response := HealthResponse{
-		Status:    "healthy",
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
 func main() {
+	app := appkit.New("{{.AppID}}", "{{.AppPort}}")
+
 	// Setup HTTP routes
-	http.HandleFunc("/healthz", healthHandler)
-	http.HandleFunc("/start", startHandler)
+	app.HandleFunc("POST /start", startHandler)
 
-	// Get port from environment variable or use default
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		appPort = "6000"
-	}
+	// GET /readyz fails until the sidecar is up. Add the components the app
+	// uses, or Workflows and Actors if it runs them, to wait for those too.
+	app.CheckSidecar(appkit.SidecarRequirements{})
 
-	// Start HTTP server
-	log.Printf("Starting HTTP server on port %s", appPort)
-	log.Fatal(http.ListenAndServe(":"+appPort, nil))
+	if err := app.Run(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func startHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Hello, World!"})
+	appkit.WriteJSON(w, http.StatusOK, map[string]string{"message": "Hello, World!"})
 }